@@ -0,0 +1,154 @@
+package compile
+
+import (
+	"fmt"
+
+	"whitehouse.id.au/microlisp/value"
+)
+
+// Op identifies the operation of an instruction.
+type Op uint8
+
+const (
+	Const          Op = iota // push Consts[A]
+	Local                    // push the variable in slot B of the frame A levels out
+	SetLocal                 // store the top of the stack in slot B of the frame A levels out
+	Global                   // push the value of the global variable named by Consts[A]
+	SetGlobal                // store the top of the stack in the global variable named by Consts[A]
+	Define                   // define the global variable named by Consts[A] as the top of the stack
+	Macro                    // replace the function on top of the stack with a macro named by Consts[A]
+	Pop                      // discard the top of the stack
+	Jump                     // continue at A
+	JumpIfNot                // pop the stack, and continue at A if the value is nil
+	JumpIfKeep               // continue at A if the top of the stack is true, otherwise pop it
+	JumpIfNotKeep            // continue at A if the top of the stack is nil, otherwise pop it
+	Closure                  // push a closure of the code Consts[A] in the current frame
+	Call                     // call a function with the A arguments above it on the stack
+	TailCall                 // call a function in place of the current call
+	Return                   // return the top of the stack to the caller
+	Frame                    // enter a frame of A slots, the first B taken from the stack
+	PopFrame                 // leave the current frame
+	Default                  // continue at B if slot A of the frame has an argument
+	Cons                     // pop a tail and a head, and push their cons
+	Append                   // pop a tail and a list, and push a copy of the list ending in the tail
+	ValueList                // replace the top of the stack with a list of its multiple values
+	Bind                     // pop the stack, and dynamically bind the special variable named by Consts[A]
+	Unbind                   // leave the innermost A dynamic bindings
+	JumpIfBound              // continue at B if the global variable named by Consts[A] is bound
+	GlobalFunction           // push the global variable named by Consts[A], to be called at B, or expand its macro in place of the call
+)
+
+var opNames = [...]string{
+	Const:          "const",
+	Local:          "local",
+	SetLocal:       "set-local",
+	Global:         "global",
+	SetGlobal:      "set-global",
+	Define:         "define",
+	Macro:          "macro",
+	Pop:            "pop",
+	Jump:           "jump",
+	JumpIfNot:      "jump-if-not",
+	JumpIfKeep:     "jump-if-keep",
+	JumpIfNotKeep:  "jump-if-not-keep",
+	Closure:        "closure",
+	Call:           "call",
+	TailCall:       "tail-call",
+	Return:         "return",
+	Frame:          "frame",
+	PopFrame:       "pop-frame",
+	Default:        "default",
+	Cons:           "cons",
+	Append:         "append",
+	ValueList:      "value-list",
+	Bind:           "bind",
+	Unbind:         "unbind",
+	JumpIfBound:    "jump-if-bound",
+	GlobalFunction: "global-function",
+}
+
+func (op Op) String() string {
+	if int(op) < len(opNames) {
+		return opNames[op]
+	}
+	return fmt.Sprintf("Op(%d)", op)
+}
+
+// operands returns the number of operands used by an operation.
+func (op Op) operands() int {
+	switch op {
+	case Local, SetLocal, Frame, Default, JumpIfBound, GlobalFunction:
+		return 2
+	case Pop, Return, PopFrame, Cons, Append, ValueList:
+		return 0
+	}
+	return 1
+}
+
+// Instr is a single instruction.
+type Instr struct {
+	Op   Op
+	A, B int
+}
+
+// Code is a compiled function, or a compiled top-level form.
+type Code struct {
+	Name   string      // name of the function, if any
+	Params *LambdaList // nil for a top-level form
+	Doc    string      // documentation string of the function, if any
+	Size   int         // number of slots in the frame of a call
+	Instrs []Instr
+	Consts []value.Value
+	Forms  map[int]value.Value // source form of each call, by address
+
+	scopes     map[int]*scope    // scope of each GlobalFunction instruction, by address
+	expansions map[int]expansion // expansion of the call of each GlobalFunction instruction
+}
+
+// expansion is the compiled expansion of a call to a macro.
+type expansion struct {
+	macro *value.Macro
+	code  *Code
+}
+
+func (c *Code) String() string {
+	switch {
+	case c.Name != "":
+		return fmt.Sprintf("#[code %s]", c.Name)
+	case c.Params != nil:
+		return "#[code lambda]"
+	}
+	return "#[code toplevel]"
+}
+
+// Equal implements the Value interface, and returns T for the same
+// code.
+func (c *Code) Equal(cmp value.Value) value.Value {
+	if x, ok := cmp.(*Code); ok && c == x {
+		return value.T
+	}
+	return value.NIL
+}
+
+// Disassemble returns a list describing each instruction, such as
+// (0 const a). Operands that refer to constants are replaced by the
+// constant, and the others are integers.
+func (c *Code) Disassemble() value.Value {
+	instrs := make([]value.Value, len(c.Instrs))
+	for pc, instr := range c.Instrs {
+		fields := []value.Value{value.Fixnum(pc), value.Intern(instr.Op.String())}
+		switch instr.Op {
+		case Const, Global, SetGlobal, Define, Macro, Closure, Bind:
+			fields = append(fields, c.Consts[instr.A])
+		case JumpIfBound, GlobalFunction:
+			fields = append(fields, c.Consts[instr.A], value.Fixnum(instr.B))
+		default:
+			operands := []int{instr.A, instr.B}[:instr.Op.operands()]
+			for _, n := range operands {
+				fields = append(fields, value.Fixnum(n))
+			}
+		}
+		instrs[pc] = value.List(fields)
+	}
+	return value.List(instrs)
+}
@@ -0,0 +1,1040 @@
+// Package compile implements a compiler of Lisp expressions to
+// bytecode, which is executed by package vm.
+//
+// Variables bound by a function or a let form are stored in slots of
+// a frame, and are addressed by the number of frames out from the
+// current frame, and the index of their slot. Any other variable is
+// global, and is addressed by name.
+package compile
+
+import "whitehouse.id.au/microlisp/value"
+
+var (
+	// shortcuts for standard types
+	T   = value.T
+	NIL = value.NIL
+)
+
+// Compile compiles an expression as a top-level form. Macros are
+// expanded using the definitions bound in env.
+func Compile(expr value.Value, env value.Environment) *Code {
+	c := &compiler{code: &Code{}, env: env}
+	c.compile(expr, true)
+	c.emit(Return, 0, 0)
+	return c.code
+}
+
+// MaxDepth, if not zero, limits the depth of nested macro expansions,
+// each of which is compiled within the last.
+var MaxDepth int
+
+// compiler holds the state of compiling a single function.
+type compiler struct {
+	code  *Code
+	env   value.Environment // environment where macros are bound
+	scope *scope            // lexical variables, or nil if none
+}
+
+// scope describes the variables of a frame, in the order of their
+// slots. Only the variables visible at the current point of
+// compilation are present; a frame may have more slots.
+type scope struct {
+	names  []string
+	parent *scope
+}
+
+// snapshot returns a copy of the scope, which is unchanged by the
+// variables bound in it later.
+func (s *scope) snapshot() *scope {
+	if s == nil {
+		return nil
+	}
+	return &scope{names: s.names, parent: s.parent.snapshot()}
+}
+
+// lookup returns the address of a lexical variable.
+func (s *scope) lookup(name string) (depth, index int, ok bool) {
+	for ; s != nil; s = s.parent {
+		// Later bindings of a name shadow earlier bindings.
+		for i := len(s.names) - 1; i >= 0; i-- {
+			if s.names[i] == name {
+				return depth, i, true
+			}
+		}
+		depth++
+	}
+	return 0, 0, false
+}
+
+// emit appends an instruction, returning its address.
+func (c *compiler) emit(op Op, a, b int) int {
+	c.code.Instrs = append(c.code.Instrs, Instr{op, a, b})
+	return len(c.code.Instrs) - 1
+}
+
+// patch sets the target of the jump at addr to the next instruction.
+func (c *compiler) patch(addr int) {
+	instr := &c.code.Instrs[addr]
+	if instr.Op == Default || instr.Op == JumpIfBound {
+		instr.B = len(c.code.Instrs)
+	} else {
+		instr.A = len(c.code.Instrs)
+	}
+}
+
+// constant adds a value to the constants of the code, returning its
+// index.
+func (c *compiler) constant(v value.Value) int {
+	for i, x := range c.code.Consts {
+		if x == v {
+			return i
+		}
+	}
+	c.code.Consts = append(c.code.Consts, v)
+	return len(c.code.Consts) - 1
+}
+
+// compile compiles an expression, which leaves its value on the
+// stack. If tail is true, then the expression is in tail position,
+// and calls are compiled as tail calls.
+func (c *compiler) compile(expr value.Value, tail bool) {
+	switch x := expr.(type) {
+	case *value.Atom:
+		c.compileVariable(x)
+		return
+	case *value.Cell:
+		if car, ok := x.Car.(*value.Atom); ok {
+			switch car.Name {
+			case "quote":
+				c.compileQuote(x)
+				return
+			case "quasiquote":
+				c.compileQuasiquote(x)
+				return
+			case "cond":
+				c.compileCond(x, tail)
+				return
+			case "if":
+				c.compileIf(x, tail)
+				return
+			case "and":
+				c.compileAndOr(x, tail, JumpIfNotKeep, T)
+				return
+			case "or":
+				c.compileAndOr(x, tail, JumpIfKeep, NIL)
+				return
+			case "when":
+				c.compileWhen(x, tail, true)
+				return
+			case "unless":
+				c.compileWhen(x, tail, false)
+				return
+			case "progn":
+				c.compileProgn(x.Cdr, tail)
+				return
+			case "unwind-protect":
+				c.compileUnwindProtect(x, tail)
+				return
+			case "multiple-value-call":
+				c.compileMultipleValueCall(x, tail)
+				return
+			case "let":
+				c.compileLet(x, tail)
+				return
+			case "let*":
+				c.compileLetStar(x, tail)
+				return
+			case "letrec":
+				c.compileLetrec(x, tail)
+				return
+			case "setq":
+				c.compileSetq(x)
+				return
+			case "set!":
+				c.compileSet(x)
+				return
+			case "lambda":
+				c.compileLambda(x, "")
+				return
+			case "label":
+				c.compileLabel(x)
+				return
+			case "defun":
+				c.compileDefun(x)
+				return
+			case "defmacro":
+				c.compileDefmacro(x)
+				return
+			case "defvar":
+				c.compileDefvar(x, false)
+				return
+			case "defparameter":
+				c.compileDefvar(x, true)
+				return
+			}
+
+			// Macros are expanded using the unevaluated
+			// arguments, and the expansion is compiled in
+			// place of the form.
+			if _, _, ok := c.scope.lookup(car.Name); !ok {
+				if v, ok := c.env.Lookup(car.Name); ok {
+					if m, ok := v.(*value.Macro); ok {
+						defer value.EnterExpansion(MaxDepth)()
+						c.compile(m.Expand(x), tail)
+						return
+					}
+				}
+			}
+		}
+		c.compileApplication(x, tail)
+		return
+	}
+
+	// All other values evaluate to themselves.
+	c.emit(Const, c.constant(expr), 0)
+}
+
+// compileVariable compiles a reference to a variable.
+func (c *compiler) compileVariable(x *value.Atom) {
+	if x == T || x == NIL || x.IsKeyword() {
+		c.emit(Const, c.constant(x), 0)
+		return
+	}
+	if depth, index, ok := c.scope.lookup(x.Name); ok {
+		c.emit(Local, depth, index)
+		return
+	}
+	c.emit(Global, c.constant(x), 0)
+}
+
+// compileApplication compiles the application of a function to a list
+// of arguments.
+func (c *compiler) compileApplication(expr *value.Cell, tail bool) {
+	// A global function may turn out to be a macro defined after
+	// the call was compiled, which is then expanded when the call
+	// is made, in the scope of the call.
+	fn := -1
+	if sym, ok := expr.Car.(*value.Atom); ok && sym != T && sym != NIL && !sym.IsKeyword() {
+		if _, _, ok := c.scope.lookup(sym.Name); !ok {
+			fn = c.emit(GlobalFunction, c.constant(sym), 0)
+			if c.code.scopes == nil {
+				c.code.scopes = map[int]*scope{}
+			}
+			c.code.scopes[fn] = c.scope.snapshot()
+		}
+	}
+	if fn < 0 {
+		c.compile(expr.Car, false)
+	}
+
+	n := 0
+	if expr.Cdr != NIL {
+		cdr, ok := expr.Cdr.(*value.Cell)
+		if !ok {
+			value.Raise(value.TypeError, "The object %s is not a list", expr.Cdr)
+		}
+		cdr.Walk(func(v value.Value) {
+			c.compile(v, false)
+			n++
+		})
+	}
+
+	if fn >= 0 {
+		c.code.Instrs[fn].B = len(c.code.Instrs)
+	}
+	c.emitCall(n, tail, expr)
+}
+
+// Expand compiles the expansion of a call to a macro that was defined
+// after the call was compiled, where the GlobalFunction instruction at
+// addr found the macro. Macros within the expansion are expanded using
+// the definitions bound in env.
+//
+// The expansion is compiled in the scope and the position of the call,
+// and its code is executed in place of the call, in the same frame.
+// The code returns the value of the call, and is kept until the call
+// finds another macro.
+func (c *Code) Expand(addr int, m *value.Macro, env value.Environment) *Code {
+	if x, ok := c.expansions[addr]; ok && x.macro == m {
+		return x.code
+	}
+
+	call := c.Instrs[addr].B
+	form := c.Forms[call].(*value.Cell)
+	ec := &compiler{
+		code:  &Code{Name: c.Name, Params: c.Params, Doc: c.Doc, Size: c.Size},
+		env:   env,
+		scope: c.scopes[addr],
+	}
+	ec.compile(m.ExpandNested(form, MaxDepth), c.Instrs[call].Op == TailCall)
+	ec.emit(Return, 0, 0)
+
+	if c.expansions == nil {
+		c.expansions = map[int]expansion{}
+	}
+	c.expansions[addr] = expansion{m, ec.code}
+	return ec.code
+}
+
+// emitCall emits a call of a function with n arguments, made by a
+// form.
+func (c *compiler) emitCall(n int, tail bool, form value.Value) {
+	op := Call
+	if tail {
+		op = TailCall
+	}
+	addr := c.emit(op, n, 0)
+	if c.code.Forms == nil {
+		c.code.Forms = map[int]value.Value{}
+	}
+	c.code.Forms[addr] = form
+}
+
+// compileQuote compiles the quote special form.
+func (c *compiler) compileQuote(expr *value.Cell) {
+	cdr, ok := expr.Cdr.(*value.Cell)
+	if !ok || cdr.Cdr != NIL {
+		value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+	}
+
+	c.emit(Const, c.constant(cdr.Car), 0)
+}
+
+// compileQuasiquote compiles the quasiquote special form.
+func (c *compiler) compileQuasiquote(expr *value.Cell) {
+	cdr, ok := expr.Cdr.(*value.Cell)
+	if !ok || cdr.Cdr != NIL {
+		value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+	}
+
+	c.compileQuasi(cdr.Car, 1)
+}
+
+// compileQuasi compiles a quasiquote template at a nesting depth.
+// Unquoted expressions are only evaluated at depth 1; nested
+// quasiquote forms increase the depth, and unquote forms within them
+// decrease it.
+func (c *compiler) compileQuasi(tmpl value.Value, depth int) {
+	cell, ok := tmpl.(*value.Cell)
+	if !ok {
+		c.emit(Const, c.constant(tmpl), 0)
+		return
+	}
+
+	// nested rebuilds the form (name x).
+	nested := func(x value.Value, depth int) {
+		c.emit(Const, c.constant(cell.Car), 0)
+		c.compileQuasi(x, depth)
+		c.emit(Const, c.constant(NIL), 0)
+		c.emit(Cons, 0, 0)
+		c.emit(Cons, 0, 0)
+	}
+
+	if x, ok := unquoted(cell, "quasiquote"); ok {
+		nested(x, depth+1)
+		return
+	}
+	if x, ok := unquoted(cell, "unquote"); ok {
+		if depth == 1 {
+			c.compile(x, false)
+			return
+		}
+		nested(x, depth-1)
+		return
+	}
+	if x, ok := unquoted(cell, "unquote-splicing"); ok {
+		if depth == 1 {
+			value.Raise(value.ProgramError, ",@ is not within a list: %s", cell)
+		}
+		nested(x, depth-1)
+		return
+	}
+
+	// Each element of the list is pushed, followed by the tail of
+	// the list, from which the list is built.
+	var splices []bool
+	var tailExpr value.Value = NIL
+	var next value.Value = cell
+	for next != NIL {
+		x, ok := next.(*value.Cell)
+		if !ok {
+			tailExpr = next
+			break
+		}
+
+		// A list such as (a . ,b) is read as (a unquote b), and
+		// so the tail is expanded as a whole.
+		if isUnquote(x) {
+			tailExpr = x
+			break
+		}
+
+		splice := false
+		if elem, ok := x.Car.(*value.Cell); ok && depth == 1 {
+			if spliced, ok := unquoted(elem, "unquote-splicing"); ok {
+				c.compile(spliced, false)
+				splice = true
+			}
+		}
+		if !splice {
+			c.compileQuasi(x.Car, depth)
+		}
+		splices = append(splices, splice)
+		next = x.Cdr
+	}
+
+	// A final spliced list is shared rather than copied, and may
+	// be improper.
+	last := len(splices) - 1
+	if splices[last] && tailExpr == NIL {
+		splices = splices[:last]
+	} else {
+		c.compileQuasi(tailExpr, depth)
+	}
+	for i := len(splices) - 1; i >= 0; i-- {
+		if splices[i] {
+			c.emit(Append, 0, 0)
+		} else {
+			c.emit(Cons, 0, 0)
+		}
+	}
+}
+
+// isUnquote reports whether a cell is an unquote or unquote-splicing
+// form.
+func isUnquote(c *value.Cell) bool {
+	_, ok := unquoted(c, "unquote")
+	if !ok {
+		_, ok = unquoted(c, "unquote-splicing")
+	}
+	return ok
+}
+
+// unquoted returns x if a cell is the form (name x).
+func unquoted(c *value.Cell, name string) (value.Value, bool) {
+	sym, ok := c.Car.(*value.Atom)
+	if !ok || sym.Name != name {
+		return nil, false
+	}
+	cdr, ok := c.Cdr.(*value.Cell)
+	if !ok || cdr.Cdr != NIL {
+		return nil, false
+	}
+	return cdr.Car, true
+}
+
+// compileCond compiles the cond special form.
+func (c *compiler) compileCond(expr *value.Cell, tail bool) {
+	checkExpr := func(ok bool) {
+		if !ok {
+			value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+		}
+	}
+
+	var ends []int
+	next := expr
+	for next.Cdr != NIL {
+		v, ok := next.Cdr.(*value.Cell)
+		checkExpr(ok)
+		next = v
+
+		clause, ok := next.Car.(*value.Cell)
+		checkExpr(ok)
+		body, ok := clause.Cdr.(*value.Cell)
+		checkExpr(ok)
+
+		c.compile(clause.Car, false)
+		skip := c.emit(JumpIfNot, 0, 0)
+		c.compileBody(body, tail)
+		ends = append(ends, c.emit(Jump, 0, 0))
+		c.patch(skip)
+	}
+	c.emit(Const, c.constant(NIL), 0)
+
+	for _, end := range ends {
+		c.patch(end)
+	}
+}
+
+// compileIf compiles the if special form.
+func (c *compiler) compileIf(expr *value.Cell, tail bool) {
+	checkExpr := func(ok bool) {
+		if !ok {
+			value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+		}
+	}
+
+	// (if test then [else])
+	cdr, ok := expr.Cdr.(*value.Cell)
+	checkExpr(ok)
+	cddr, ok := cdr.Cdr.(*value.Cell)
+	checkExpr(ok)
+	var otherwise value.Value = NIL
+	if cddr.Cdr != NIL {
+		cdddr, ok := cddr.Cdr.(*value.Cell)
+		checkExpr(ok && cdddr.Cdr == NIL)
+		otherwise = cdddr.Car
+	}
+
+	c.compile(cdr.Car, false)
+	alt := c.emit(JumpIfNot, 0, 0)
+	c.compile(cddr.Car, tail)
+	end := c.emit(Jump, 0, 0)
+	c.patch(alt)
+	c.compile(otherwise, tail)
+	c.patch(end)
+}
+
+// compileAndOr compiles the and or the or special forms, which stop
+// evaluating when the jump is taken. The value of an empty form is
+// empty.
+func (c *compiler) compileAndOr(expr *value.Cell, tail bool, jump Op, empty value.Value) {
+	forms := c.forms(expr)
+	if len(forms) == 0 {
+		c.emit(Const, c.constant(empty), 0)
+		return
+	}
+
+	var ends []int
+	last := len(forms) - 1
+	for _, form := range forms[:last] {
+		c.compile(form, false)
+		ends = append(ends, c.emit(jump, 0, 0))
+	}
+	c.compile(forms[last], tail)
+
+	for _, end := range ends {
+		c.patch(end)
+	}
+}
+
+// forms returns the forms following the operator of a special form.
+func (c *compiler) forms(expr *value.Cell) []value.Value {
+	var forms []value.Value
+	next := expr.Cdr
+	for next != NIL {
+		cell, ok := next.(*value.Cell)
+		if !ok {
+			value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+		}
+		forms = append(forms, cell.Car)
+		next = cell.Cdr
+	}
+	return forms
+}
+
+// compileWhen compiles the when special form, or the unless special
+// form if want is false.
+func (c *compiler) compileWhen(expr *value.Cell, tail bool, want bool) {
+	// (when test body1 ... bodyN)
+	cdr, ok := expr.Cdr.(*value.Cell)
+	if !ok {
+		value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+	}
+
+	c.compile(cdr.Car, false)
+	if want {
+		skip := c.emit(JumpIfNot, 0, 0)
+		c.compileProgn(cdr.Cdr, tail)
+		end := c.emit(Jump, 0, 0)
+		c.patch(skip)
+		c.emit(Const, c.constant(NIL), 0)
+		c.patch(end)
+	} else {
+		body := c.emit(JumpIfNot, 0, 0)
+		c.emit(Const, c.constant(NIL), 0)
+		end := c.emit(Jump, 0, 0)
+		c.patch(body)
+		c.compileProgn(cdr.Cdr, tail)
+		c.patch(end)
+	}
+}
+
+// compileProgn compiles an implicit progn. An empty progn is nil.
+func (c *compiler) compileProgn(forms value.Value, tail bool) {
+	if forms == NIL {
+		c.emit(Const, c.constant(NIL), 0)
+		return
+	}
+	list, ok := forms.(*value.Cell)
+	if !ok {
+		value.Raise(value.ProgramError, "implicit progn must be a list: %s", forms)
+	}
+	c.compileBody(list, tail)
+}
+
+// compileUnwindProtect compiles the unwind-protect special form, as a
+// call to dynamic-wind, where the cleanup forms are the after thunk.
+func (c *compiler) compileUnwindProtect(expr *value.Cell, tail bool) {
+	// (unwind-protect protected cleanup1 ... cleanupN)
+	cdr, ok := expr.Cdr.(*value.Cell)
+	if !ok {
+		value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+	}
+
+	cleanup := cdr.Cdr
+	if cleanup == NIL {
+		cleanup = value.Cons(NIL, NIL)
+	}
+
+	// The primitive is a constant, so that it cannot be redefined.
+	lambda := value.Intern("lambda")
+	c.compile(value.List([]value.Value{
+		value.DynamicWind,
+		value.List([]value.Value{lambda, NIL, NIL}),
+		value.List([]value.Value{lambda, NIL, cdr.Car}),
+		value.Cons(lambda, value.Cons(NIL, cleanup)),
+	}), tail)
+}
+
+// compileMultipleValueCall compiles the multiple-value-call special
+// form, as a call to apply with the list of every value of each form.
+func (c *compiler) compileMultipleValueCall(expr *value.Cell, tail bool) {
+	// (multiple-value-call function form1 ... formN)
+	cdr, ok := expr.Cdr.(*value.Cell)
+	if !ok {
+		value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+	}
+	forms := c.forms(cdr)
+
+	c.emit(Const, c.constant(value.Apply), 0)
+	c.compile(cdr.Car, false)
+	if len(forms) == 0 {
+		c.emit(Const, c.constant(NIL), 0)
+	}
+	for _, form := range forms {
+		c.compile(form, false)
+		c.emit(ValueList, 0, 0)
+	}
+	for i := 1; i < len(forms); i++ {
+		c.emit(Append, 0, 0)
+	}
+
+	c.emitCall(2, tail, expr)
+}
+
+// compileBody compiles a proper list of forms, which are evaluated in
+// order, leaving the value of the last.
+func (c *compiler) compileBody(list *value.Cell, tail bool) {
+	for {
+		next, ok := list.Cdr.(*value.Cell)
+		if !ok {
+			break
+		}
+		c.compile(list.Car, false)
+		c.emit(Pop, 0, 0)
+		list = next
+	}
+	if list.Cdr != NIL {
+		value.Raise(value.ProgramError, "cannot evaluate an improper list: %s", list)
+	}
+	c.compile(list.Car, tail)
+}
+
+// compileLet compiles the let special form.
+func (c *compiler) compileLet(expr *value.Cell, tail bool) {
+	names, inits, body := parseLet(expr)
+	n := countSpecial(names)
+
+	// Initial values are evaluated before any are bound.
+	for _, init := range inits {
+		c.compile(init, false)
+	}
+	c.emit(Frame, len(names), len(names))
+	c.scope = &scope{parent: c.scope}
+	for _, name := range names {
+		c.bind(name)
+	}
+	c.compileBindingBody(body, tail, n)
+	c.scope = c.scope.parent
+	c.emit(PopFrame, 0, 0)
+}
+
+// compileLetStar compiles the let* special form, where each initial
+// value may refer to the variables bound before it.
+func (c *compiler) compileLetStar(expr *value.Cell, tail bool) {
+	names, inits, body := parseLet(expr)
+	n := countSpecial(names)
+
+	c.emit(Frame, len(names), 0)
+	c.scope = &scope{parent: c.scope}
+	for i, init := range inits {
+		c.compile(init, false)
+		c.emit(SetLocal, 0, i)
+		c.emit(Pop, 0, 0)
+		c.bind(names[i])
+	}
+	c.compileBindingBody(body, tail, n)
+	c.scope = c.scope.parent
+	c.emit(PopFrame, 0, 0)
+}
+
+// compileLetrec compiles the letrec special form, where each initial
+// value may refer to any of the variables, allowing the definition of
+// mutually recursive functions. Special variables are bound in turn,
+// as if by let*.
+func (c *compiler) compileLetrec(expr *value.Cell, tail bool) {
+	names, inits, body := parseLet(expr)
+	n := countSpecial(names)
+
+	c.emit(Frame, len(names), 0)
+	c.scope = &scope{parent: c.scope}
+	for _, name := range names {
+		if value.IsSpecial(name) {
+			name = ""
+		}
+		c.scope.names = append(c.scope.names, name)
+	}
+	for i, init := range inits {
+		c.compile(init, false)
+		c.emit(SetLocal, 0, i)
+		c.emit(Pop, 0, 0)
+		if value.IsSpecial(names[i]) {
+			c.emit(Local, 0, i)
+			c.emit(Bind, c.constant(value.Intern(names[i])), 0)
+		}
+	}
+	c.compileBindingBody(body, tail, n)
+	c.scope = c.scope.parent
+	c.emit(PopFrame, 0, 0)
+}
+
+// bind adds a variable to the innermost scope, in the next slot of
+// its frame. A special variable is instead bound dynamically to the
+// value in the slot, and so is not visible in the scope.
+func (c *compiler) bind(name string) {
+	slot := len(c.scope.names)
+	if value.IsSpecial(name) {
+		c.emit(Local, 0, slot)
+		c.emit(Bind, c.constant(value.Intern(name)), 0)
+		name = ""
+	}
+	c.scope.names = append(c.scope.names, name)
+}
+
+// compileBindingBody compiles the body of a form that makes n dynamic
+// bindings. The bindings are left when the body returns, so it is
+// only in tail position if there are none.
+func (c *compiler) compileBindingBody(body *value.Cell, tail bool, n int) {
+	c.compileBody(body, tail && n == 0)
+	if n > 0 {
+		c.emit(Unbind, n, 0)
+	}
+}
+
+// countSpecial returns the number of special variables named.
+func countSpecial(names []string) int {
+	n := 0
+	for _, name := range names {
+		if value.IsSpecial(name) {
+			n++
+		}
+	}
+	return n
+}
+
+// parseLet destructures a let special form into the names of its
+// variables, their initial values and its body. A binding may be
+// written as name, (name) or (name init); the initial value is nil if
+// omitted.
+func parseLet(expr *value.Cell) ([]string, []value.Value, *value.Cell) {
+	checkExpr := func(ok bool) {
+		if !ok {
+			value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+		}
+	}
+
+	// (cadr (let ((var1 init1) ... (varN initN)) body1 ... bodyN))
+	cdr, ok := expr.Cdr.(*value.Cell)
+	checkExpr(ok)
+
+	// (cddr (let bindings body1 ... bodyN))
+	body, ok := cdr.Cdr.(*value.Cell)
+	checkExpr(ok)
+
+	var names []string
+	var inits []value.Value
+	if cdr.Car != NIL {
+		bindings, ok := cdr.Car.(*value.Cell)
+		checkExpr(ok)
+
+		bindings.Walk(func(v value.Value) {
+			var init value.Value = NIL
+			if binding, ok := v.(*value.Cell); ok {
+				v = binding.Car
+				if binding.Cdr != NIL {
+					rest, ok := binding.Cdr.(*value.Cell)
+					checkExpr(ok && rest.Cdr == NIL)
+					init = rest.Car
+				}
+			}
+			name, ok := v.(*value.Atom)
+			checkExpr(ok)
+
+			names = append(names, name.Name)
+			inits = append(inits, init)
+		})
+	}
+
+	return names, inits, body
+}
+
+// compileSetq compiles the setq special form, which assigns the value
+// of each expression to the variable preceding it, in turn.
+func (c *compiler) compileSetq(expr *value.Cell) {
+	checkExpr := func(ok bool) {
+		if !ok {
+			value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+		}
+	}
+
+	// (setq var1 form1 ... varN formN)
+	if expr.Cdr == NIL {
+		c.emit(Const, c.constant(NIL), 0)
+		return
+	}
+	next := expr.Cdr
+	for next != NIL {
+		pair, ok := next.(*value.Cell)
+		checkExpr(ok)
+		name, ok := pair.Car.(*value.Atom)
+		checkExpr(ok)
+		rest, ok := pair.Cdr.(*value.Cell)
+		checkExpr(ok)
+
+		c.compile(rest.Car, false)
+		c.compileAssign(name)
+		if rest.Cdr != NIL {
+			c.emit(Pop, 0, 0)
+		}
+		next = rest.Cdr
+	}
+}
+
+// compileSet compiles the set! special form, which assigns the value
+// of an expression to a variable.
+func (c *compiler) compileSet(expr *value.Cell) {
+	checkExpr := func(ok bool) {
+		if !ok {
+			value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+		}
+	}
+
+	// (set! var form)
+	cdr, ok := expr.Cdr.(*value.Cell)
+	checkExpr(ok)
+	name, ok := cdr.Car.(*value.Atom)
+	checkExpr(ok)
+	cddr, ok := cdr.Cdr.(*value.Cell)
+	checkExpr(ok && cddr.Cdr == NIL)
+
+	c.compile(cddr.Car, false)
+	c.compileAssign(name)
+}
+
+// compileAssign compiles the assignment of the value on top of the
+// stack to a variable.
+func (c *compiler) compileAssign(sym *value.Atom) {
+	if sym == T || sym == NIL || sym.IsKeyword() {
+		value.Raise(value.ProgramError, "cannot assign to constant: %s", sym)
+	}
+	if depth, index, ok := c.scope.lookup(sym.Name); ok {
+		c.emit(SetLocal, depth, index)
+		return
+	}
+	c.emit(SetGlobal, c.constant(sym), 0)
+}
+
+// compileLambda compiles the lambda special form, naming the function.
+func (c *compiler) compileLambda(expr *value.Cell, name string) {
+	checkExpr := func(ok bool) {
+		if !ok {
+			value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+		}
+	}
+
+	// (cadr (lambda (arg1 ... argN) body1 ... bodyN))
+	cdr, ok := expr.Cdr.(*value.Cell)
+	checkExpr(ok && cdr.Cdr != NIL)
+
+	// (cddr (lambda args body1 body2 ... bodyN))
+	body, ok := cdr.Cdr.(*value.Cell)
+	checkExpr(ok)
+
+	c.compileFunction(name, cdr.Car, body)
+}
+
+// compileLabel compiles the label special form.
+func (c *compiler) compileLabel(expr *value.Cell) {
+	checkExpr := func(ok bool) {
+		if !ok {
+			value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+		}
+	}
+
+	// (cadr (label name (lambda ...)))
+	cdr, ok := expr.Cdr.(*value.Cell)
+	checkExpr(ok && cdr.Cdr != NIL)
+	label, ok := cdr.Car.(*value.Atom)
+	checkExpr(ok)
+
+	// (caddr (label name (lambda ...)))
+	cddr, ok := cdr.Cdr.(*value.Cell)
+	checkExpr(ok && cddr.Cdr == NIL)
+	caddr, ok := cddr.Car.(*value.Cell)
+	checkExpr(ok)
+
+	// The function is created in a frame where it is able to
+	// reference the name defined by the label special form.
+	c.emit(Frame, 1, 0)
+	c.scope = &scope{names: []string{label.Name}, parent: c.scope}
+	c.compileLambda(caddr, label.Name)
+	c.emit(SetLocal, 0, 0)
+	c.scope = c.scope.parent
+	c.emit(PopFrame, 0, 0)
+}
+
+// compileDefun compiles the definition of a permanent function.
+func (c *compiler) compileDefun(expr *value.Cell) {
+	symbol, argExpr, body := parseDefinition(expr)
+
+	// Functions are always defined globally, even when the defun
+	// form is within a function.
+	c.compileFunction(symbol.Name, argExpr, body)
+	c.emit(Define, c.constant(symbol), 0)
+	c.emit(Pop, 0, 0)
+	c.emit(Const, c.constant(symbol), 0)
+}
+
+// compileDefvar compiles the definition of a special variable by the
+// defvar special form, or by defparameter if always is true. The
+// variable is declared special as soon as the form is compiled, so
+// that bindings of it compiled later are dynamic. Defvar only
+// evaluates the value if the variable is unbound, and binds nil if
+// there is no value.
+func (c *compiler) compileDefvar(expr *value.Cell, always bool) {
+	checkExpr := func(ok bool) {
+		if !ok {
+			value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+		}
+	}
+
+	// (defvar name [value [documentation]])
+	// (defparameter name value [documentation])
+	cdr, ok := expr.Cdr.(*value.Cell)
+	checkExpr(ok)
+	symbol, ok := cdr.Car.(*value.Atom)
+	checkExpr(ok && symbol != T && symbol != NIL && !symbol.IsKeyword())
+	var init value.Value = NIL
+	if cdr.Cdr != NIL {
+		cddr, ok := cdr.Cdr.(*value.Cell)
+		checkExpr(ok)
+		if cddr.Cdr != NIL {
+			doc, ok := cddr.Cdr.(*value.Cell)
+			checkExpr(ok && doc.Cdr == NIL)
+		}
+		init = cddr.Car
+	} else {
+		checkExpr(!always)
+	}
+
+	value.DeclareSpecial(symbol.Name)
+	skip := -1
+	if !always {
+		skip = c.emit(JumpIfBound, c.constant(symbol), 0)
+	}
+	c.compile(init, false)
+	c.emit(Define, c.constant(symbol), 0)
+	c.emit(Pop, 0, 0)
+	if skip >= 0 {
+		c.patch(skip)
+	}
+	c.emit(Const, c.constant(symbol), 0)
+}
+
+// compileDefmacro compiles the definition of a permanent macro.
+func (c *compiler) compileDefmacro(expr *value.Cell) {
+	symbol, argExpr, body := parseDefinition(expr)
+
+	c.compileFunction(symbol.Name, argExpr, body)
+	c.emit(Macro, c.constant(symbol), 0)
+	c.emit(Define, c.constant(symbol), 0)
+	c.emit(Pop, 0, 0)
+	c.emit(Const, c.constant(symbol), 0)
+}
+
+// parseDefinition destructures a definition special form, such as
+// defun or defmacro, into its name, lambda list and body.
+func parseDefinition(expr *value.Cell) (*value.Atom, value.Value, *value.Cell) {
+	checkExpr := func(ok bool) {
+		if !ok {
+			value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+		}
+	}
+
+	// (cadr (defun fn (arg1 ... argN) body1 ... bodyN))
+	cdr, ok := expr.Cdr.(*value.Cell)
+	checkExpr(ok && cdr.Cdr != NIL)
+	symbol, ok := cdr.Car.(*value.Atom)
+	checkExpr(ok)
+
+	// (caddr (defun fn (arg1 ... argN) body1 ... bodyN))
+	cddr, ok := cdr.Cdr.(*value.Cell)
+	checkExpr(ok && cddr.Cdr != NIL)
+
+	// (cdddr (defun fn (arg1 ... argN) body1 ... bodyN))
+	body, ok := cddr.Cdr.(*value.Cell)
+	checkExpr(ok)
+
+	return symbol, cddr.Car, body
+}
+
+// compileFunction compiles a lambda list and body as a separate code
+// object, and the creation of a closure of it.
+func (c *compiler) compileFunction(name string, argExpr value.Value, body *value.Cell) {
+	params := ParseLambdaList(argExpr)
+	doc, body := ParseDocumentation(body)
+	fc := &compiler{
+		code:  &Code{Name: name, Params: params, Doc: doc},
+		env:   c.env,
+		scope: &scope{parent: c.scope},
+	}
+
+	// The arguments occupy slots in the order of the lambda
+	// list, and each supplied-p variable follows its parameter.
+	// Initial values of parameters without arguments are
+	// computed by the function's prologue, and may refer to the
+	// parameters preceding them. Special parameters are bound
+	// dynamically as they are reached.
+	for _, name := range params.Required {
+		fc.bind(name)
+	}
+	prologue := func(param Param) {
+		slot := len(fc.scope.names)
+		skip := fc.emit(Default, slot, 0)
+		fc.compile(param.Init, false)
+		fc.emit(SetLocal, 0, slot)
+		fc.emit(Pop, 0, 0)
+		fc.patch(skip)
+
+		fc.bind(param.Name)
+		if param.Supplied != "" {
+			fc.bind(param.Supplied)
+		}
+	}
+	for _, param := range params.Optional {
+		prologue(param)
+	}
+	if params.Rest != "" {
+		fc.bind(params.Rest)
+	}
+	for _, param := range params.Keys {
+		prologue(param)
+	}
+	fc.code.Size = len(fc.scope.names)
+
+	fc.compileBindingBody(body, true, countSpecial(params.Names()))
+	fc.emit(Return, 0, 0)
+
+	c.emit(Closure, c.constant(fc.code), 0)
+}
@@ -0,0 +1,107 @@
+package compile_test
+
+import (
+	"strings"
+	"testing"
+
+	"whitehouse.id.au/microlisp/compile"
+	"whitehouse.id.au/microlisp/read"
+	"whitehouse.id.au/microlisp/scan"
+	"whitehouse.id.au/microlisp/value"
+)
+
+func TestCompile(t *testing.T) {
+	testCases := []struct {
+		expr string
+		want string // disassembly of the compiled form
+	}{
+		{"(quote a)", "((0 const a) (1 return))"},
+		{"x", "((0 global x) (1 return))"},
+		{":k", "((0 const :k) (1 return))"},
+		{"(car x)", "((0 global-function car 2) (1 global x) (2 tail-call 1) (3 return))"},
+		{"(if x (quote a))",
+			"((0 global x) (1 jump-if-not 4) (2 const a) (3 jump 5) (4 const nil) (5 return))"},
+		{"(and x y)",
+			"((0 global x) (1 jump-if-not-keep 3) (2 global y) (3 return))"},
+		{"(or x y)",
+			"((0 global x) (1 jump-if-keep 3) (2 global y) (3 return))"},
+		{"(let ((a x)) (setq a y) a)",
+			"((0 global x) (1 frame 1 1) (2 global y) (3 set-local 0 0) (4 pop) (5 local 0 0) (6 pop-frame) (7 return))"},
+		{"(lambda (a) a)", "((0 closure #[code lambda]) (1 return))"},
+		{"(defun f (a) a)",
+			"((0 closure #[code f]) (1 define f) (2 pop) (3 const f) (4 return))"},
+		{"`(a ,x ,@y)",
+			"((0 const a) (1 global x) (2 global y) (3 cons) (4 cons) (5 return))"},
+		{"`(,@x b)",
+			"((0 global x) (1 const b) (2 const nil) (3 cons) (4 append) (5 return))"},
+		{"(defvar *c* x)",
+			"((0 jump-if-bound *c* 4) (1 global x) (2 define *c*) (3 pop) (4 const *c*) (5 return))"},
+		{"(defparameter *c* x)",
+			"((0 global x) (1 define *c*) (2 pop) (3 const *c*) (4 return))"},
+		{"(let ((*c* y)) *c*)",
+			"((0 global y) (1 frame 1 1) (2 local 0 0) (3 bind *c*) (4 global *c*) (5 unbind 1) (6 pop-frame) (7 return))"},
+
+		{"(setq t x)", "#[error: cannot assign to constant: t]"},
+		{"(if)", "#[error: ill-formed special form: (if)]"},
+		{"(defparameter *c*)", "#[error: ill-formed special form: (defparameter *c*)]"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			expr := read.New(scan.New(strings.NewReader(tc.expr))).Read()
+			got := disassemble(expr)
+			if got.String() != tc.want {
+				t.Errorf("want %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+// disassemble compiles an expression, returning its disassembly or
+// the error raised by compiling it.
+func disassemble(expr value.Value) (v value.Value) {
+	defer func() {
+		if r := recover(); r != nil {
+			v = r.(*value.Condition)
+		}
+	}()
+	return compile.Compile(expr, value.NewEnv(value.SystemEnvironment)).Disassemble()
+}
+
+func TestFunction(t *testing.T) {
+	expr := read.New(scan.New(strings.NewReader("(lambda (a &optional (b a) c) b)"))).Read()
+	code := compile.Compile(expr, value.SystemEnvironment).Consts[0].(*compile.Code)
+
+	// Slots are a, b and c, with a prologue for the initial value
+	// of each optional parameter.
+	if code.Size != 3 {
+		t.Errorf("want 3 slots, got %d", code.Size)
+	}
+	want := "((0 default 1 4) (1 local 0 0) (2 set-local 0 1) (3 pop)" +
+		" (4 default 2 8) (5 const nil) (6 set-local 0 2) (7 pop)" +
+		" (8 local 0 1) (9 return))"
+	if got := code.Disassemble().String(); got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
+
+func TestDocumentation(t *testing.T) {
+	testCases := []struct {
+		expr string
+		doc  string
+		want string
+	}{
+		{`(lambda (a) "Return a." a)`, "Return a.", "((0 local 0 0) (1 return))"},
+		{`(lambda (a) "Return a.")`, "", `((0 const "Return a.") (1 return))`},
+	}
+
+	for _, tc := range testCases {
+		expr := read.New(scan.New(strings.NewReader(tc.expr))).Read()
+		code := compile.Compile(expr, value.SystemEnvironment).Consts[0].(*compile.Code)
+		if code.Doc != tc.doc {
+			t.Errorf("%s: want documentation %q, got %q", tc.expr, tc.doc, code.Doc)
+		}
+		if got := code.Disassemble().String(); got != tc.want {
+			t.Errorf("%s: want %s, got %s", tc.expr, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package compile
+
+import "whitehouse.id.au/microlisp/value"
+
+// LambdaList describes the parameters of a function.
+//
+//	(var1 ... varN
+//	 &optional var (var init) (var init supplied-p) ...
+//	 &rest var
+//	 &key var (var init) ((keyword var) init supplied-p) ...
+//	 &allow-other-keys)
+//
+// A symbol in place of the list, or a dotted list such as (a . rest),
+// binds the remaining arguments as if by &rest.
+type LambdaList struct {
+	Required       []string
+	Optional       []Param
+	Rest           string // empty if there is no rest parameter
+	Key            bool   // true if keyword arguments are accepted
+	Keys           []Param
+	AllowOtherKeys bool
+	Source         value.Value // the lambda list as written
+}
+
+// Param is an optional or keyword parameter, which is bound to the
+// value of Init if no argument is supplied. If Supplied is not empty,
+// then it names a variable that is bound to whether the argument was
+// supplied.
+type Param struct {
+	Name     string
+	Keyword  string // keyword identifying the argument of a keyword parameter
+	Init     value.Value
+	Supplied string
+}
+
+// lambdaListKeywords orders the sections of a lambda list.
+var lambdaListKeywords = map[string]int{
+	"&optional":         1,
+	"&rest":             2,
+	"&body":             2,
+	"&key":              3,
+	"&allow-other-keys": 4,
+}
+
+// ParseDocumentation separates the documentation string of a function
+// from the forms of its body. A leading string is documentation only
+// if other forms follow it, as otherwise it is the value of the body.
+func ParseDocumentation(body *value.Cell) (string, *value.Cell) {
+	if doc, ok := body.Car.(value.String); ok {
+		if rest, ok := body.Cdr.(*value.Cell); ok {
+			return string(doc), rest
+		}
+	}
+	return "", body
+}
+
+// ParseLambdaList parses the lambda list of a lambda special form.
+func ParseLambdaList(expr value.Value) *LambdaList {
+	checkExpr := func(ok bool) {
+		if !ok {
+			value.Raise(value.ProgramError, "ill-formed lambda list: %s", expr)
+		}
+	}
+
+	// parseParam parses (var init supplied-p), where the var is
+	// parsed by parseVar.
+	parseParam := func(v value.Value, parseVar func(value.Value)) Param {
+		var param Param
+		param.Init = value.NIL
+
+		cell, ok := v.(*value.Cell)
+		if !ok {
+			parseVar(v)
+			return param
+		}
+		parseVar(cell.Car)
+
+		if cell.Cdr == value.NIL {
+			return param
+		}
+		rest, ok := cell.Cdr.(*value.Cell)
+		checkExpr(ok)
+		param.Init = rest.Car
+
+		if rest.Cdr == value.NIL {
+			return param
+		}
+		rest, ok = rest.Cdr.(*value.Cell)
+		checkExpr(ok && rest.Cdr == value.NIL)
+		supplied, ok := rest.Car.(*value.Atom)
+		checkExpr(ok)
+		param.Supplied = supplied.Name
+
+		return param
+	}
+
+	params := &LambdaList{Source: expr}
+	state := "&required"
+	next := expr
+	for next != value.NIL {
+		// A symbol in the tail of the list names a rest parameter.
+		if atom, ok := next.(*value.Atom); ok {
+			checkExpr(lambdaListKeywords[state] < lambdaListKeywords["&rest"] && params.Rest == "")
+			params.Rest = atom.Name
+			break
+		}
+
+		cell, ok := next.(*value.Cell)
+		if !ok {
+			value.Raise(value.TypeError, "The object %s is not a list", expr)
+		}
+		next = cell.Cdr
+
+		if atom, ok := cell.Car.(*value.Atom); ok {
+			if order, ok := lambdaListKeywords[atom.Name]; ok {
+				checkExpr(order > lambdaListKeywords[state])
+				checkExpr(state != "&rest" || params.Rest != "")
+				checkExpr(atom.Name != "&allow-other-keys" || state == "&key")
+
+				switch atom.Name {
+				case "&body":
+					state = "&rest"
+				case "&key":
+					params.Key = true
+					state = atom.Name
+				case "&allow-other-keys":
+					params.AllowOtherKeys = true
+					state = atom.Name
+				default:
+					state = atom.Name
+				}
+				continue
+			}
+		}
+
+		switch state {
+		case "&required":
+			name, ok := cell.Car.(*value.Atom)
+			if !ok {
+				value.Raise(value.TypeError, "The object %s is not a symbol", cell.Car)
+			}
+			params.Required = append(params.Required, name.Name)
+		case "&optional":
+			var name string
+			param := parseParam(cell.Car, func(v value.Value) {
+				sym, ok := v.(*value.Atom)
+				checkExpr(ok)
+				name = sym.Name
+			})
+			param.Name = name
+			params.Optional = append(params.Optional, param)
+		case "&rest":
+			name, ok := cell.Car.(*value.Atom)
+			checkExpr(ok && params.Rest == "")
+			params.Rest = name.Name
+		case "&key":
+			// A parameter is either var, in which case the
+			// keyword is :var, or (keyword var).
+			var name, keyword string
+			param := parseParam(cell.Car, func(v value.Value) {
+				switch v := v.(type) {
+				case *value.Atom:
+					name, keyword = v.Name, ":"+v.Name
+				case *value.Cell:
+					kw, ok := v.Car.(*value.Atom)
+					checkExpr(ok)
+					rest, ok := v.Cdr.(*value.Cell)
+					checkExpr(ok && rest.Cdr == value.NIL)
+					sym, ok := rest.Car.(*value.Atom)
+					checkExpr(ok)
+					name, keyword = sym.Name, kw.Name
+				}
+			})
+			checkExpr(name != "")
+			param.Name, param.Keyword = name, keyword
+			params.Keys = append(params.Keys, param)
+		default:
+			checkExpr(false) // nothing may follow &allow-other-keys
+		}
+	}
+	checkExpr(state != "&rest" || params.Rest != "")
+
+	return params
+}
+
+// Names returns the names of the variables bound by the lambda list,
+// in the order they are bound.
+func (l *LambdaList) Names() []string {
+	names := append([]string(nil), l.Required...)
+	params := func(params []Param) {
+		for _, param := range params {
+			names = append(names, param.Name)
+			if param.Supplied != "" {
+				names = append(names, param.Supplied)
+			}
+		}
+	}
+	params(l.Optional)
+	if l.Rest != "" {
+		names = append(names, l.Rest)
+	}
+	params(l.Keys)
+	return names
+}
+
+// Match matches a list of arguments to the parameters of the function
+// named name, raising an error if they are not accepted. It returns the arguments of the
+// optional parameters, the list bound to the rest parameter, and the
+// arguments of the keyword parameters. An optional or keyword
+// parameter without an argument has a nil value.
+func (l *LambdaList) Match(name string, args []value.Value) (optional []value.Value, rest value.Value, keys []value.Value) {
+	min := len(l.Required)
+	max := min + len(l.Optional)
+	if l.Rest != "" || l.Key {
+		max = -1
+	}
+	if name == "" {
+		name = "lambda"
+	}
+	value.AssertArity(name, min, max, len(args))
+	args = args[min:]
+
+	optional = make([]value.Value, len(l.Optional))
+	for i := range optional {
+		if len(args) == 0 {
+			break
+		}
+		optional[i] = args[0]
+		args = args[1:]
+	}
+
+	rest = value.NIL
+	if l.Rest != "" {
+		rest = value.List(args)
+	}
+
+	if l.Key {
+		keys = l.matchKeys(args)
+	}
+
+	return optional, rest, keys
+}
+
+// matchKeys matches the arguments following their keywords in args
+// to keyword parameters.
+func (l *LambdaList) matchKeys(args []value.Value) []value.Value {
+	if len(args)%2 != 0 {
+		value.Raise(value.ProgramError, "odd number of keyword arguments: %s", value.List(args))
+	}
+
+	// Unknown keywords are permitted if the lambda list allows
+	// them, or the caller passes :allow-other-keys with a true
+	// value.
+	allowOtherKeys := l.AllowOtherKeys
+	for i := 0; i < len(args); i += 2 {
+		if isSymbol(args[i], ":allow-other-keys") {
+			allowOtherKeys = args[i+1] != value.NIL
+			break
+		}
+	}
+
+	if !allowOtherKeys {
+	Check:
+		for i := 0; i < len(args); i += 2 {
+			if isSymbol(args[i], ":allow-other-keys") {
+				continue
+			}
+			for _, param := range l.Keys {
+				if isSymbol(args[i], param.Keyword) {
+					continue Check
+				}
+			}
+			value.Raise(value.ProgramError, "unknown keyword argument: %s", args[i])
+		}
+	}
+
+	keys := make([]value.Value, len(l.Keys))
+	for j, param := range l.Keys {
+		// The leftmost occurrence of a keyword is used.
+		for i := 0; i < len(args); i += 2 {
+			if isSymbol(args[i], param.Keyword) {
+				keys[j] = args[i+1]
+				break
+			}
+		}
+	}
+	return keys
+}
+
+// isSymbol reports whether v is the symbol named name.
+func isSymbol(v value.Value, name string) bool {
+	atom, ok := v.(*value.Atom)
+	return ok && atom.Name == name
+}
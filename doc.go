@@ -10,6 +10,31 @@ a value. If an environment has a parent, then bindings are inherited.
 
 The evaluator typically provides an environment implicitly.
 
+The setq and set! special forms assign a new value to an existing
+binding, found by searching from the innermost environment outwards.
+Assigning to an unbound variable is an error.
+
+Evaluating an unbound variable is also an error. McCarthy's manual
+instead has an unbound variable evaluate to its own symbol; this
+behaviour is available with the -self-quote flag.
+
+	(setq var1 form1 ... varN formN)
+	(set! var form)
+
+Bindings are lexically scoped, except for those of special variables,
+which are declared by defvar and defparameter. A binding of a special
+variable by let, let*, letrec or a lambda list is dynamically scoped:
+it is seen by every function called while the binding form is
+evaluated, and the previous value is restored when the form is exited,
+whether normally, by an error or by a continuation. By convention, the
+names of special variables begin and end with *.
+
+	(defvar name [value [documentation]])
+	(defparameter name value [documentation])
+
+Defvar only assigns the value if the variable is unbound, and binds
+nil if the value is omitted. Defparameter always assigns the value.
+
 Variables.
 
 	system-environment	Primitives are bound in this environment.
@@ -19,14 +44,388 @@ Functions.
 
 	environment-bindings	The environment's bindings represented as an association list.
 
+# Conditionals
+
+The symbol nil, which is also the empty list, is false. Every other
+value is true, and predicates return t to indicate truth. This rule
+is shared by cond and the other conditional special forms.
+
+	(cond (test1 body1 ...) ... (testN bodyN ...))
+	(if test then [else])
+	(when test body1 ... bodyN)
+	(unless test body1 ... bodyN)
+
+The and and or special forms evaluate their forms from left to right,
+stopping once the result is decided. The result is the value of the
+last form evaluated; (and) is t and (or) is nil.
+
+	(and form1 ... formN)
+	(or form1 ... formN)
+
+The progn special form evaluates its forms in order, returning the
+value of the last.
+
+	(progn form1 ... formN)
+
+# Numbers
+
+Numbers evaluate to themselves. An integer is written in decimal, with
+an optional sign and an optional trailing decimal point, such as 42,
+-7 or 1. Integers are of unlimited size:
+small integers are held in 64 bits, and arithmetic that overflows them
+produces a larger integer.
+
+A ratio is an exact rational number that is not an integer, written
+as a numerator and denominator, such as 3/4, and held in lowest terms.
+A float is a double precision floating point number, written with a
+decimal point, an exponent or both, such as 1.5, .5 or 1.5e10. A
+float too large to be represented, such as 1e400, cannot be read.
+
+Arithmetic on an integer and a ratio is exact, and produces a ratio,
+or an integer if the result is one. Arithmetic on a float and any
+other number produces a float. Equal compares numbers of the same kind
+by value, so 1 and 1.0 are not equal, but = compares any numbers.
+Float arithmetic that overflows, or whose result is undefined, raises
+an arithmetic-error rather than produce an infinity.
+
+Functions.
+
+	(+ n1 ... nN)		The sum of the arguments, or 0.
+	(- n1 ... nN)		The first argument less the rest, or the negation of a single argument.
+	(* n1 ... nN)		The product of the arguments, or 1.
+	(/ n1 ... nN)		The first argument divided by the rest, or the reciprocal of a single argument.
+	(quotient n d)		The quotient of integers, truncated towards zero.
+	(remainder n d)		The remainder of the quotient, which has the sign of the dividend.
+	(mod n d)		The remainder of the quotient rounded down, which has the sign of the divisor.
+	(= n1 ... nN)		Whether the arguments are equal.
+	(< n1 ... nN)		Whether each argument is less than the next; similarly >, <= and >=.
+	(zerop n)		Whether a number is zero.
+	(abs n)			The absolute value.
+	(expt base power)	A number raised to a power, which is exact for a rational base and an integer power.
+	(gcd n1 ... nN)		The greatest common divisor of integers, or 0.
+	(lcm n1 ... nN)		The least common multiple of integers, or 1.
+	(float n)		The number as a float.
+	(floor n [d])		The quotient rounded down to an integer, and the remainder, as two values.
+	(ceiling n [d])		Similarly, the quotient rounded up.
+	(truncate n [d])	Similarly, the quotient rounded towards zero.
+	(round n [d])		Similarly, the quotient rounded to the nearest integer, or the even integer if halfway.
+	(numerator n)		The numerator of a rational number.
+	(denominator n)		The denominator of a rational number, which is positive.
+
+# Strings
+
+A string is an immutable sequence of characters, written in double
+quotes, which evaluates to itself. Within a string, a backslash
+escapes a double quote or a backslash, and introduces \n for newline,
+\t for tab, \r for carriage return, or a character given by its
+hexadecimal code point and a semicolon, such as \x3bb; for λ. Strings
+are printed in the same form.
+
+Strings are indexed by character, from zero. Equal compares strings by
+their characters.
+
+Functions.
+
+	(string-length s)		The number of characters in a string.
+	(substring s start [end])	The characters from start up to end, or the end of the string.
+	(string-append s1 ... sN)	A string of the characters of each argument in turn.
+	(string= s1 ... sN)		Whether the arguments have the same characters.
+	(string< s1 ... sN)		Whether each string precedes the next, comparing characters by code point; similarly string>, string<= and string>=.
+	(string-upcase s)		The string with each character in upper case.
+	(string-search pattern s)	The index of the first occurrence of a pattern in a string, or nil.
+	(string->symbol s)		The symbol named by a string.
+	(symbol->string sym)		The name of a symbol.
+	(string->list s)		A list of the characters in a string.
+	(number->string n)		The written representation of a number.
+
+# Characters
+
+A character is a Unicode code point, written as #\ followed by the
+character, such as #\a or #\λ, which evaluates to itself. A character
+may also be written by name, such as #\space, or by its hexadecimal
+code point, such as #\x41 for A. The names are null, alarm, backspace,
+tab, newline, return, escape, space and delete. Characters that have a
+name, or are not printable, are printed in these forms.
+
+Functions.
+
+	(char->integer c)		The code point of a character.
+	(integer->char n)		The character of a code point.
+	(char-upcase c)			The upper case of a character.
+	(char-alphabetic? c)		Whether a character is a letter.
+	(char-whitespace? c)		Whether a character is white space.
+	(char= c1 ... cN)		Whether the arguments are the same character.
+	(char< c1 ... cN)		Whether each character precedes the next by code point; similarly char>, char<= and char>=.
+
+# Lambda Lists
+
+The parameters of a function created by lambda, defun or defmacro
+are described by a lambda list. Required parameters are followed by
+optional parameters, which are bound to the value of an initial form
+if no argument is supplied, and then by a rest parameter, which is
+bound to a list of the remaining arguments.
+
+Keyword parameters follow, and are bound to the argument following
+their keyword. A keyword is a symbol beginning with a colon, such as
+:name, which evaluates to itself. Unless &allow-other-keys ends the
+lambda list, or the caller supplies :allow-other-keys with a true
+value, passing an unknown keyword is an error.
+
+	(var1 ... varN
+	 &optional opt1 (opt2 init) (opt3 init supplied-p) ...
+	 &rest rest
+	 &key key1 (key2 init) ((:keyword key3) init supplied-p) ...
+	 &allow-other-keys)
+
+A supplied-p variable is bound to t if an argument was supplied for
+its parameter, and nil otherwise.
+
+A dotted list, such as (a . rest), or a symbol in place of the list
+also binds a rest parameter. In defmacro, &body is a synonym for
+&rest.
+
+# Multiple Values
+
+A form may return any number of values, such as a quotient and a
+remainder. Wherever a value is used, such as an argument, a test or
+the value of a variable, only the primary value is seen, which is the
+first value, or nil if there are none. The values of the last form of
+a function body, progn or conditional are those of the enclosing form.
+The REPL prints each value on its own line, or "; no values" if there
+are none.
+
+	(values value1 ... valueN)
+
+The multiple-value-call special form applies a function to every
+value of each form, in order.
+
+	(multiple-value-call function form1 ... formN)
+
+The multiple-value-bind macro evaluates its body with the variables
+bound to the values of a form. Variables without a value are nil, and
+extra values are ignored.
+
+	(multiple-value-bind (var1 ... varN) form body1 ... bodyN)
+
+Functions.
+
+	values			Return each argument as a value.
+	multiple-value-list	A macro, which is the list of every value of a form.
+
 # Errors
 
-The error system is very simple. If an error value is thrown, it stops
-the current evaluation, and is handled and printed by the REPL.
+An error is a condition, which is a value with a type and a message.
+If an error is raised and not handled, it stops the current evaluation,
+and is printed by the REPL.
+
+Condition types form a hierarchy, where each type is a subtype of the
+type above it.
+
+	condition
+		warning
+		serious-condition
+			budget-exhausted		An evaluation applied more functions than permitted by -max-steps.
+			error
+				simple-error		Raised by the error function.
+				control-error		Transfer to a restart or continuation that is no longer active.
+				unbound-variable
+				program-error
+					arity-error	A function called with the wrong number of arguments.
+					ill-formed-special-form
+				type-error
+					not-a-pair
+					not-a-function
+					not-a-number
+					not-a-string
+					not-a-character
+				arithmetic-error
+					division-by-zero
+				stack-overflow		Calls or expansions nested more deeply than permitted by -max-depth.
+
+An ill-formed special form, such as (if), raises its error when the
+form is analysed or compiled, which happens before any part of the
+expression containing it is evaluated. The error cannot be handled by
+a handler established in the same expression, so that
+(handler-case (if) (ill-formed-special-form () nil)) raises the error
+rather than returning nil. Errors raised while expanding a macro
+behave likewise.
+
+The handler-case macro evaluates a form, and if a condition of
+one of the clause types is signalled, control is transferred to the
+first such clause. Its value is that of the clause's body, with the
+optional variable bound to the condition.
+
+	(handler-case form (type1 ([var]) body1 ...) ... (typeN ([var]) bodyN ...))
+
+The handler-bind macro evaluates its body with handlers
+established, without transferring control. Each handler is a function
+called with a signalled condition of its type, and may decline to
+handle it by returning, or handle it by transferring control, such as
+by invoking a restart. Handlers established within a handler are
+searched first.
+
+	(handler-bind ((type1 handler1) ... (typeN handlerN)) body1 ... bodyN)
+
+The restart-case macro evaluates a form with restarts
+established. If a restart is invoked, control is transferred to the
+restart-case, and its value is that of the restart's body, with the
+parameters of its lambda list bound to the arguments.
+
+	(restart-case form (name1 lambda-list1 body1 ...) ... (nameN lambda-listN bodyN ...))
+
+If an error is raised and not handled while restarts are established,
+the REPL lists them and waits for one to be chosen by its name or
+number. The REPL then prompts for each required parameter of the
+restart, and evaluates an expression for its value. Other expressions
+are evaluated, and choosing abort returns to the REPL.
+
+	> (restart-case (error "boom") (use-value (x) x))
+	#[error: boom]
+	Restarts:
+	  0: use-value
+	  1: abort
+	debug> use-value
+	x: (quote fixed)
+	fixed
+
+An error records the calls in progress when it was raised, from the
+innermost outwards. Entering backtrace at the REPL, or in the
+debugger, prints the backtrace of the last error, with each call's
+arguments and the form it was called from. A tail call replaces the
+call it was made from.
+
+	> (defun inner (x) (car x))
+	inner
+	> (defun outer (x) (list (inner x)))
+	outer
+	> (outer (quote a))
+	#[error: car: a is not a pair]
+	> backtrace
+	  0: (inner a) from (inner x)
+	  1: (outer a) from (outer (quote a))
 
 # Functions
 
-	error		Raise an error value with a message composed of its arguments.
-	ignore-errors	Invoke a function, trapping any errors thrown as a return value.
+	error		Raise an error with a message composed of its arguments, or raise a condition.
+			Strings contribute their characters, as in (error "no such file:" name).
+	ignore-errors	Invoke a function, trapping any errors raised as a return value.
+	make-condition	Make a condition of a type, with a message composed of the remaining arguments.
+	condition-type	The symbol naming the type of a condition.
+	signal		Signal a condition to the established handlers, returning nil if not handled.
+	compute-restarts	A list of the established restarts, innermost first.
+	find-restart	The innermost established restart with a name, or nil.
+	invoke-restart	Transfer control to a restart, or the innermost restart with a name, with the remaining arguments.
+
+The unwind-protect special form evaluates the protected form, and
+then the cleanup forms, however the protected form is exited: by
+returning normally, by raising an error, even if trapped by
+ignore-errors, or by invoking a continuation. Its value is that of
+the protected form.
+
+	(unwind-protect protected cleanup1 ... cleanupN)
+
+The dynamic-wind function is similar, calling three functions of no
+arguments. The after function is called whenever control leaves the
+thunk, and the before function whenever control enters it, including
+by resuming a continuation captured within it.
+
+	(dynamic-wind before thunk after)
+
+A function defined by defun or label is named, and a function made by
+lambda is anonymous. A function prints with its name and lambda list,
+and a primitive with its name. Errors raised when a function is called
+with the wrong number of arguments are prefixed by its name. A string
+before the other forms of a function's body is its documentation.
+
+	(label ff (lambda (x) x))	; #[compound-function ff (x)]
+	(lambda (x) x)			; #[compound-function lambda (x)]
+	car				; #[compiled-function car]
+
+	function-name		The symbol naming a function, or nil if it is anonymous.
+	function-lambda-list	The lambda list of a function, or nil if it is not known.
+	function-documentation	The documentation string of a function, or nil if it has none.
+
+# Continuations
+
+A continuation is the rest of a computation, waiting for a value. The
+call/cc function calls a function with the continuation of the call
+to call/cc as its argument. Invoking the continuation with a value
+abandons the current computation, and instead returns the value from
+that call to call/cc.
+
+	(call/cc (lambda (k) (cons (quote a) (k (quote b)))))	; b
+
+With the vm evaluator, a continuation may be invoked any number of
+times, even after call/cc has returned, including from a later
+expression entered at the REPL. This is sufficient to implement
+generators and backtracking search. A continuation captured within
+ignore-errors traps errors again when it is resumed.
+
+With the analyze evaluator, a continuation may only be used to escape
+from the call to call/cc before it returns.
+
+The catch macro evaluates its body with a tag established. If a value
+is thrown to the tag, control is transferred to the innermost catch
+with that tag, and the value is returned from it. Tags are compared by
+eq, so numbers, strings and lists with the same elements are the same
+tag, and throwing to a tag that is not established is an error.
+
+	(catch tag body1 ... bodyN)
+	(throw tag value)
+
+Functions.
+
+	call/cc				Call a function with the current continuation.
+	call-with-current-continuation	Synonym for call/cc.
+	throw				Transfer a value to the innermost catch with a tag.
+
+# Macros
+
+A macro is a function that transforms a form into a new form, which
+is evaluated in place of the original. The arguments of a macro call
+are passed to the macro unevaluated.
+
+	(defmacro name (arg1 ... argN) body1 ... bodyN)
+
+Expansions are conveniently written as quasiquote templates, where
+`x is read as (quasiquote x), ,x as (unquote x) and ,@x as
+(unquote-splicing x). Within a template, unquoted expressions are
+evaluated, and the elements of unquote-spliced lists are inserted.
+
+	`(cond (,test ,@body))
+
+Functions.
+
+	macroexpand-1	Expand a form once if it is a call to a macro.
+	macroexpand	Expand a form until it is no longer a call to a macro.
+
+# Evaluators
+
+Expressions are evaluated by one of two evaluators, selected with the
+-evaluator flag. The vm evaluator, which is the default, compiles each
+expression to bytecode, which is executed by a stack machine. The
+analyze evaluator converts each expression into a tree of Go closures.
+
+Both evaluators expand macros when a function is defined. A call to a
+macro defined after the function is expanded when the call is first
+made, and the expansion is evaluated in place of the call.
+
+The -max-steps flag limits the number of functions applied by the
+evaluation of each expression, including those applied to expand
+macros. An evaluation that exceeds the limit is stopped, with a
+budget-exhausted condition as its value. The condition is not
+signalled, so it cannot be handled by handler-case or ignore-errors,
+and the cleanup forms of unwind-protect are not evaluated.
+
+The -max-depth flag limits the depth of nested calls, which defaults
+to 100000. A tail call does not add to the depth. A call nested more
+deeply raises a stack-overflow error, which may be handled like any
+other error. The flag also limits the depth of macro expansions, each
+expanding to a form containing the next, which are expanded before
+the form is evaluated.
+
+Functions.
+
+	disassemble	Describe the bytecode of a compiled function, or of a form, as a list of instructions.
 */
 package main // import "whitehouse.id.au/microlisp"
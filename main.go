@@ -13,12 +13,26 @@ var (
 
 	// TODO(danielwhite): Teach -q flag to suppress results of evaluation.
 	quietFlag = flag.Bool("q", false, "Suppress the REPL prompt")
+
+	selfQuoteFlag = flag.Bool("self-quote", false, "Evaluate unbound variables to their own symbol, as in McCarthy's manual")
+
+	maxStepsFlag = flag.Int("max-steps", 0, "Stop each evaluation after it applies `n` functions, or 0 for no limit")
+
+	maxDepthFlag = flag.Int("max-depth", run.MaxDepth, "Raise an error when calls are nested more than `n` deep, or 0 for no limit")
 )
 
+func init() {
+	flag.Var(&run.DefaultEvaluator, "evaluator", "Evaluate expressions with the `name`d evaluator: analyze or vm")
+}
+
 func main() {
 	flag.Parse()
 	log.SetFlags(0)
 
+	run.SelfQuoting = *selfQuoteFlag
+	run.MaxSteps = *maxStepsFlag
+	run.MaxDepth = *maxDepthFlag
+
 	// If a file is specified, load before proceeding.
 	if *loadFlag != "" {
 		if err := run.Load(*loadFlag); err != nil {
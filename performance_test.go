@@ -66,7 +66,7 @@ func BenchmarkInvoke(b *testing.B) {
 		name string
 		args string
 	}{
-		{"list", "1 2 3"},
+		{"list", "(quote 1) (quote 2) (quote 3)"},
 		{"car", "(quote (x y z))"},
 		{"cdr", "(quote (a b c))"},
 		{"caar", "(quote ((a . 1) (b . 2) (c . 3)))"},
@@ -75,10 +75,10 @@ func BenchmarkInvoke(b *testing.B) {
 		{"caddr", "(quote (a b c))"},
 		{"cadar", "(quote ((a a') b c))"},
 		{"caddar", "(quote ((a a' a'') b c))"},
-		{"cons", "1 2"},
+		{"cons", "(quote 1) (quote 2)"},
 		{"equal", "(quote (a (b c) d (e (f) g))) (quote (a (b c) d (e (f) g)))"},
-		{"apply", "list (list d e f g)"},
-		{"apply", "list a b c (list d e f g)"},
+		{"apply", "list (quote (d e f g))"},
+		{"apply", "list (quote a) (quote b) (quote c) (quote (d e f g))"},
 		{"lambda", "(a) a"},
 	}
 
@@ -101,6 +101,81 @@ func BenchmarkInvoke(b *testing.B) {
 	}
 }
 
+// BenchmarkInterpret measures each evaluator running the definition
+// of eval, which makes many calls to a function analysed or compiled
+// once.
+func BenchmarkInterpret(b *testing.B) {
+	scanner := scan.New(bytes.NewReader(src))
+	reader := read.New(scanner)
+	v := reader.Read()
+	if err, ok := v.(value.Error); ok {
+		b.Fatalf("benchmark failed due to parse error: %s", err)
+	}
+
+	// Interpret an expression using the definition of eval.
+	expr := run.ReadString(`(eval (quote ((lambda (x y) (cons (car x) y))
+                                              (quote (a b))
+                                              (cdr (quote (c d)))))
+                                      nil)`)
+
+	forEachEvaluator(b, func(b *testing.B) {
+		defer run.Reset()
+		if _, err := unwrapEval(v); err != nil {
+			b.Fatalf("benchmark failed due to eval error: %s", err)
+		}
+		for i := 0; i < b.N; i++ {
+			if _, err := unwrapEval(expr); err != nil {
+				b.Fatalf("benchmark failed due to eval error: %s", err)
+			}
+		}
+	})
+}
+
+// BenchmarkCall measures each evaluator calling a function, defined
+// once, which invokes a primitive.
+func BenchmarkCall(b *testing.B) {
+	testCases := []struct {
+		name string
+		args string
+	}{
+		{"car", "(quote (x y z))"},
+		{"cons", "(quote 1) (quote 2)"},
+		{"apply", "list (quote a) (quote b) (quote c) (quote (d e f g))"},
+		{"lambda", "(a) a"},
+	}
+
+	forEachEvaluator(b, func(b *testing.B) {
+		defer run.Reset()
+		for _, tc := range testCases {
+			b.Run(tc.name, func(b *testing.B) {
+				src := fmt.Sprintf("(defun benchmark () (%s %s))", tc.name, tc.args)
+				if _, err := unwrapEval(run.ReadString(src)); err != nil {
+					b.Fatalf("benchmark failed due to eval error: %s", err)
+				}
+
+				v := run.ReadString("(benchmark)")
+				for i := 0; i < b.N; i++ {
+					if _, err := unwrapEval(v); err != nil {
+						b.Fatalf("benchmark failed due to eval error: %s", err)
+					}
+				}
+			})
+		}
+	})
+}
+
+// forEachEvaluator runs a benchmark once with each evaluator as the
+// default.
+func forEachEvaluator(b *testing.B, f func(b *testing.B)) {
+	for _, e := range []run.Evaluator{run.Analyzer, run.Compiler} {
+		b.Run(e.String(), func(b *testing.B) {
+			defer func(old run.Evaluator) { run.DefaultEvaluator = old }(run.DefaultEvaluator)
+			run.DefaultEvaluator = e
+			f(b)
+		})
+	}
+}
+
 func readFile(name string) []byte {
 	b, err := os.ReadFile(name)
 	if err != nil {
@@ -111,7 +186,7 @@ func readFile(name string) []byte {
 
 func unwrapEval(expr value.Value) (value.Value, error) {
 	v := run.Eval(expr)
-	if err, ok := v.(value.Error); ok {
+	if err, ok := v.(*value.Condition); ok {
 		return nil, err
 	}
 	return v, nil
@@ -22,32 +22,105 @@ func (r *Reader) readList() (value.Value, error) {
 	for {
 		tok := r.scanner.Next()
 		switch tok.Type {
-		case scan.EOF:
-			return nil, errEOF
-		case scan.Error:
-			return nil, errors.New(tok.Text)
 		case scan.Comment:
 			continue // comments are skipped
 		case scan.RightParen:
 			tail.Cdr = value.NIL
 			return head.Cdr, nil
 		case scan.Atom:
-			atom := value.Intern(tok.Text)
-			cell := &value.Cell{Car: atom}
-			tail.Cdr = cell
-			tail = cell
-		case scan.LeftParen:
-			list, err := r.readList()
-			if err != nil {
-				return nil, err
+			// A dot introduces the final cdr of an improper list.
+			if tok.Text == "." && tail != head {
+				cdr, err := r.readNext()
+				if err != nil {
+					return nil, err
+				}
+				if err := r.readClose(); err != nil {
+					return nil, err
+				}
+				tail.Cdr = cdr
+				return head.Cdr, nil
 			}
-			cell := &value.Cell{Car: list}
-			tail.Cdr = cell
-			tail = cell
+		}
+
+		v, err := r.readExpr(tok)
+		if err != nil {
+			return nil, err
+		}
+		cell := &value.Cell{Car: v}
+		tail.Cdr = cell
+		tail = cell
+	}
+}
+
+// readClose reads the closing parenthesis of a dotted list.
+func (r *Reader) readClose() error {
+	for {
+		tok := r.scanner.Next()
+		switch tok.Type {
+		case scan.Comment:
+			continue // comments are skipped
+		case scan.RightParen:
+			return nil
+		case scan.EOF:
+			return errEOF
+		case scan.Error:
+			return errors.New(tok.Text)
 		default:
-			return nil, fmt.Errorf("unexpected token in list: %s", tok)
+			return fmt.Errorf("more than one object follows . in list: %s", tok)
+		}
+	}
+}
+
+// readNext reads the next expression, skipping comments.
+func (r *Reader) readNext() (value.Value, error) {
+	tok := r.scanner.Next()
+	for tok.Type == scan.Comment {
+		tok = r.scanner.Next()
+	}
+	return r.readExpr(tok)
+}
+
+// readExpr reads the expression starting with a token.
+func (r *Reader) readExpr(tok scan.Token) (value.Value, error) {
+	switch tok.Type {
+	case scan.Atom:
+		n, err := value.ParseNumber(tok.Text)
+		if err == value.ErrNotNumber {
+			return value.Intern(tok.Text), nil
 		}
+		return n, err
+	case scan.String:
+		return value.String(tok.Text), nil
+	case scan.Char:
+		if c, ok := value.ParseChar(tok.Text); ok {
+			return c, nil
+		}
+		return nil, fmt.Errorf("unknown character name: #\\%s", tok.Text)
+	case scan.LeftParen:
+		return r.readList()
+	case scan.Quasiquote:
+		return r.readAbbrev("quasiquote")
+	case scan.Unquote:
+		return r.readAbbrev("unquote")
+	case scan.UnquoteSplicing:
+		return r.readAbbrev("unquote-splicing")
+	case scan.EOF:
+		return nil, errEOF
+	case scan.Error:
+		return nil, errors.New(tok.Text)
+	default:
+		return nil, fmt.Errorf("unexpected token: %s", tok)
+	}
+}
+
+// readAbbrev reads the expression following an abbreviation such as
+// `x, which is read as (quasiquote x).
+func (r *Reader) readAbbrev(name string) (value.Value, error) {
+	v, err := r.readNext()
+	if err != nil {
+		return nil, err
 	}
+	return value.Cons(value.Intern(name), value.Cons(v, value.NIL)), nil
 }
 
 // New initialises a reader for parsing Lisp expressions.
@@ -60,24 +133,18 @@ func New(s *scan.Scanner) *Reader {
 func (r *Reader) Read() value.Value {
 Next:
 	switch tok := r.scanner.Next(); tok.Type {
-	case scan.Atom:
-		return value.Intern(tok.Text)
-	case scan.LeftParen:
-		v, err := r.readList()
-		if err != nil {
-			return value.Error(err.Error())
-		}
-		return v
 	case scan.RightParen:
 		return value.Error("unbalanced closed parenthesis")
 	case scan.EOF:
 		return value.EOF
-	case scan.Error:
-		return value.Error(tok.Text)
 	case scan.Comment:
 		// comments are ignored, so scan again
 		goto Next
 	default:
-		return value.Error(fmt.Sprintf("unsupported token: %s", tok))
+		v, err := r.readExpr(tok)
+		if err != nil {
+			return value.Error(err.Error())
+		}
+		return v
 	}
 }
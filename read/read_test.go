@@ -2,6 +2,7 @@ package read_test
 
 import (
 	"errors"
+	"math/big"
 	"reflect"
 	"strings"
 	"testing"
@@ -14,6 +15,7 @@ import (
 
 func TestRead(t *testing.T) {
 	a, b, c := value.Intern("a"), value.Intern("b"), value.Intern("c")
+	big, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
 
 	testCases := []struct {
 		expr string
@@ -33,6 +35,43 @@ func TestRead(t *testing.T) {
                   (a ; example
                    b)`,
 			value.Cons(a, value.Cons(b, value.NIL))},
+		{"(a . b)", value.Cons(a, b)},
+		{"(a b . c)", value.Cons(a, value.Cons(b, c))},
+		{"(a . (b))", value.Cons(a, value.Cons(b, value.NIL))},
+		{"(. a)", value.Cons(value.Intern("."), value.Cons(a, value.NIL))},
+		{"`a", value.Cons(value.Intern("quasiquote"), value.Cons(a, value.NIL))},
+		{"(,a ,@b)",
+			value.Cons(
+				value.Cons(value.Intern("unquote"), value.Cons(a, value.NIL)),
+				value.Cons(
+					value.Cons(value.Intern("unquote-splicing"), value.Cons(b, value.NIL)),
+					value.NIL))},
+		{"`; comment\n a", value.Cons(value.Intern("quasiquote"), value.Cons(a, value.NIL))},
+		{"42", value.Fixnum(42)},
+		{"(-7 +3 007)", value.List([]value.Value{value.Fixnum(-7), value.Fixnum(3), value.Fixnum(7)})},
+		{"-123456789012345678901234567890", value.NewBigInt(big)},
+		{"(3/4 -6/8 4/2 1.5 -.5 1e3 1.5E-3)", value.List([]value.Value{
+			run.EvalString("(/ 3 4)"), run.EvalString("(/ -3 4)"), value.Fixnum(2),
+			value.Float(1.5), value.Float(-0.5), value.Float(1000), value.Float(0.0015)})},
+		{"(1/0 1/-2 1/ /2 .e1 1e 1.5e+ 1.5.2 1..)", value.List([]value.Value{
+			value.Intern("1/0"), value.Intern("1/-2"), value.Intern("1/"), value.Intern("/2"),
+			value.Intern(".e1"), value.Intern("1e"), value.Intern("1.5e+"), value.Intern("1.5.2"), value.Intern("1..")})},
+		{"(1. -7. 12345678901234567890.)", value.List([]value.Value{
+			value.Fixnum(1), value.Fixnum(-7), run.EvalString("12345678901234567890")})},
+		{"(a 1e400)", value.Error("number out of range: 1e400")},
+		{"(+ - 1+ 1a --1)", value.List([]value.Value{
+			value.Intern("+"), value.Intern("-"), value.Intern("1+"), value.Intern("1a"), value.Intern("--1")})},
+		{`("a" "\"q\"" a"b")`, value.List([]value.Value{
+			value.String("a"), value.String(`"q"`), a, value.String("b")})},
+		{`"abc`, value.Error("unterminated string")},
+		{`(#\a #\space #\) #\x41 #\x #\( #a)`, value.List([]value.Value{
+			value.Char('a'), value.Char(' '), value.Char(')'), value.Char('A'), value.Char('x'), value.Char('('), value.Intern("#a")})},
+		{`#\spaces`, value.Error(`unknown character name: #\spaces`)},
+		{`#\xd800`, value.Error(`unknown character name: #\xd800`)},
+		{`#\`, value.Error("incomplete character literal")},
+		{"(a . b c)", value.Error(`more than one object follows . in list: Atom: "c"`)},
+		{"(a . b", value.Error("premature EOF")},
+		{"`)", value.Error("unexpected token: RightParen")},
 		{")", value.Error("unbalanced closed parenthesis")},
 		{"(", value.Error("premature EOF")},
 	}
@@ -46,6 +85,40 @@ func TestRead(t *testing.T) {
 	}
 }
 
+func TestStringRoundTrip(t *testing.T) {
+	testCases := []value.String{
+		"",
+		"hello, world",
+		`a "quoted" \ backslash`,
+		"tab\tnewline\nreturn\r",
+		"\x00\x1b[0m\u2028",
+		"λx. ÿ 世界 🙂",
+	}
+	for _, want := range testCases {
+		t.Run(want.String(), func(t *testing.T) {
+			got := run.ReadString(want.String())
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("want %s, got %s", want, got)
+			}
+		})
+	}
+}
+
+func TestCharRoundTrip(t *testing.T) {
+	testCases := []value.Char{
+		'a', 'A', '(', ')', ';', '"', '\\', '#', 'x', 'λ', '世', '🙂',
+		' ', '\n', '\t', '\r', 0, 0x7f, 0xa0, 0x2028, 0x10ffff,
+	}
+	for _, want := range testCases {
+		t.Run(want.String(), func(t *testing.T) {
+			got := run.ReadString(want.String())
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("want %s, got %s", want, got)
+			}
+		})
+	}
+}
+
 func TestReadMultiple(t *testing.T) {
 	a, b, c := value.Intern("a"), value.Intern("b"), value.Intern("c")
 
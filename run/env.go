@@ -1,6 +1,10 @@
 package run
 
-import "whitehouse.id.au/microlisp/value"
+import (
+	"whitehouse.id.au/microlisp/compile"
+	"whitehouse.id.au/microlisp/value"
+	"whitehouse.id.au/microlisp/vm"
+)
 
 // UserEnvironment is an environment which inherits from the system
 // environment. Definitions introduced by a user will be bound here.
@@ -9,12 +13,52 @@ import "whitehouse.id.au/microlisp/value"
 // not change the behaviour of the system environment.
 var UserEnvironment value.Environment
 
+// machine executes compiled code, with its global variables bound in
+// the user environment.
+var machine *vm.Machine
+
 func init() {
 	Reset()
+
+	// Macro expansion depends on macros defined by the user, so
+	// these primitives expand in the user environment.
+	value.SystemEnvironment.Define("macroexpand-1", value.Func1(func(form value.Value) value.Value {
+		v, _ := value.MacroExpand1(form, UserEnvironment)
+		return v
+	}))
+	value.SystemEnvironment.Define("macroexpand", value.Func1(func(form value.Value) value.Value {
+		v, _ := value.MacroExpand(form, UserEnvironment)
+		return v
+	}))
+	value.SystemEnvironment.Define("disassemble", value.Func1(disassemble))
+}
+
+// disassemble describes the bytecode of a compiled function or macro,
+// or of the function named by a symbol. A form is compiled first.
+func disassemble(v value.Value) value.Value {
+	if sym, ok := v.(*value.Atom); ok {
+		if fn, ok := UserEnvironment.Lookup(sym.Name); ok {
+			v = fn
+		}
+	}
+	if m, ok := v.(*value.Macro); ok {
+		v = m.Expander
+	}
+
+	switch x := v.(type) {
+	case *vm.Closure:
+		return x.Code.Disassemble()
+	case *value.Cell:
+		return compile.Compile(x, UserEnvironment).Disassemble()
+	}
+	value.Raise(value.TypeError, "disassemble: %s is not a compiled function", v)
+	panic("not possible")
 }
 
 // Reset the environment for the runtime to an empty state.
 func Reset() {
+	value.ForgetSpecials()
 	UserEnvironment = value.NewEnv(value.SystemEnvironment)
 	UserEnvironment.Define("user-environment", UserEnvironment)
+	machine = &vm.Machine{Env: UserEnvironment}
 }
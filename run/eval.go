@@ -2,22 +2,89 @@
 package run
 
 import (
+	"fmt"
 	"strings"
 
+	"whitehouse.id.au/microlisp/compile"
 	"whitehouse.id.au/microlisp/read"
 	"whitehouse.id.au/microlisp/scan"
 	"whitehouse.id.au/microlisp/value"
 )
 
+// An Evaluator is a strategy used by [Eval] to evaluate expressions.
+type Evaluator int
+
+const (
+	// Analyzer converts an expression into a tree of Go closures,
+	// which evaluate by recursion.
+	Analyzer Evaluator = iota
+
+	// Compiler compiles an expression to bytecode, which is
+	// executed by a stack machine.
+	Compiler
+)
+
+var evaluatorNames = [...]string{
+	Analyzer: "analyze",
+	Compiler: "vm",
+}
+
+func (e Evaluator) String() string {
+	if int(e) < len(evaluatorNames) {
+		return evaluatorNames[e]
+	}
+	return fmt.Sprintf("Evaluator(%d)", e)
+}
+
+// Set implements flag.Value, selecting an evaluator by name.
+func (e *Evaluator) Set(name string) error {
+	for i, s := range evaluatorNames {
+		if s == name {
+			*e = Evaluator(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown evaluator: %s", name)
+}
+
+// DefaultEvaluator is used by [Eval] to evaluate expressions.
+var DefaultEvaluator = Compiler
+
 // Eval applies rules to an expression, and returns an expression that
-// is the value.
+// is the value. If an error is raised and not handled, the value is
+// the condition, which holds a backtrace.
+//
+// If the evaluation applies more than MaxSteps functions, then it is
+// stopped, and the value is a condition of type
+// [value.BudgetExhausted], which cannot be handled by the expression.
 func Eval(expr value.Value) (v value.Value) {
+	// An evaluation begun by the debugger, during another
+	// evaluation, shares its budget.
+	if budget == nil {
+		budget = &value.Budget{Max: MaxSteps}
+		defer func() { budget = nil }()
+	}
 	defer func() {
 		if r := recover(); r != nil {
-			v = r.(value.Error)
+			c, ok := r.(*value.Condition)
+			if !ok {
+				panic(r)
+			}
+			v = c
 		}
 	}()
-	v = eval(expr, UserEnvironment)
+	switch DefaultEvaluator {
+	case Compiler:
+		value.CallStack = machine.Backtrace
+		machine.SelfQuoting = SelfQuoting
+		machine.Budget = budget
+		machine.MaxDepth = MaxDepth
+		compile.MaxDepth = MaxDepth
+		v = machine.Run(compile.Compile(expr, UserEnvironment))
+	default:
+		value.CallStack = backtrace
+		v = eval(expr, UserEnvironment)
+	}
 	return
 }
 
@@ -39,139 +106,942 @@ func EvalString(expr string) value.Value {
 	return Eval(v)
 }
 
+// SelfQuoting enables the behaviour described by McCarthy's manual,
+// where an unbound variable evaluates to its own symbol rather than
+// raising an error.
+var SelfQuoting = false
+
+// MaxSteps limits the number of functions applied by each evaluation
+// by [Eval], or is zero for no limit.
+var MaxSteps = 0
+
+// MaxDepth limits the depth of nested calls made by an evaluation, and
+// of nested macro expansions, or is zero for no limit. A call or
+// expansion nested more deeply raises a stack-overflow error, rather
+// than exhausting the stack of the process.
+var MaxDepth = 100000
+
+// budget counts the steps of the evaluation in progress, if any.
+var budget *value.Budget
+
 var (
 	// shortcuts for standard types
 	T   = value.T
 	NIL = value.NIL
 
-	unspecified = value.Error("#[unspecified return value]")
-	unassigned  = value.Error("#[unassigned]")
+	unassigned = value.Error("#[unassigned]")
 )
 
+// A proc is the analysis of an expression, which is evaluated by
+// calling it with an environment.
+//
+// Analysis happens once, converting an expression into a tree of Go
+// closures, so that the syntax of a form is not examined again each
+// time it is evaluated.
+type proc func(env value.Environment) value.Value
+
+// eval analyses and then evaluates an expression in an environment.
 func eval(expr value.Value, env value.Environment) value.Value {
+	return analyze(expr, env, false)(env)
+}
+
+// analyze converts an expression into a proc. Macros are expanded
+// using the definitions bound in env, unless a lexical variable of
+// the same name shadows them; see [lexicalEnv].
+//
+// If tail is true, then the expression is in tail position of a
+// closure's body. Calls to closures in tail position are returned as
+// a tailCall to be made by apply, rather than by recursion, so that
+// tail calls run in constant Go stack space.
+func analyze(expr value.Value, env value.Environment, tail bool) proc {
 	switch x := expr.(type) {
 	case *value.Atom:
-		if x == T {
-			return T
+		return analyzeVariable(x)
+	case *value.Cell:
+		if car, ok := x.Car.(*value.Atom); ok {
+			switch car.Name {
+			case "quote":
+				return analyzeQuote(x)
+			case "quasiquote":
+				return analyzeQuasiquote(x, env)
+			case "cond":
+				return analyzeCond(x, env, tail)
+			case "if":
+				return analyzeIf(x, env, tail)
+			case "and":
+				return analyzeAnd(x, env, tail)
+			case "or":
+				return analyzeOr(x, env, tail)
+			case "when":
+				return analyzeWhen(x, env, tail, true)
+			case "unless":
+				return analyzeWhen(x, env, tail, false)
+			case "progn":
+				return analyzeProgn(x.Cdr, env, tail)
+			case "unwind-protect":
+				return analyzeUnwindProtect(x, env)
+			case "multiple-value-call":
+				return analyzeMultipleValueCall(x, env, tail)
+			case "let":
+				return analyzeLet(x, env, tail)
+			case "let*":
+				return analyzeLetStar(x, env, tail)
+			case "letrec":
+				return analyzeLetrec(x, env, tail)
+			case "setq":
+				return analyzeSetq(x, env)
+			case "set!":
+				return analyzeSet(x, env)
+			case "lambda":
+				return analyzeLambda(x, env, "")
+			case "label":
+				return analyzeLabel(x, env)
+			case "defun":
+				return analyzeDefun(x, env)
+			case "defmacro":
+				return analyzeDefmacro(x, env)
+			case "defvar":
+				return analyzeDefvar(x, env, false)
+			case "defparameter":
+				return analyzeDefvar(x, env, true)
+			}
+
+			// Macros are expanded using the unevaluated
+			// arguments, and the expansion is analysed in
+			// place of the form. A lexical variable of the
+			// same name is bound to a placeholder, which
+			// shadows the macro.
+			if v, ok := env.Lookup(car.Name); ok {
+				if m, ok := v.(*value.Macro); ok {
+					defer value.EnterExpansion(MaxDepth)()
+					return analyze(m.Expand(x), env, tail)
+				}
+			}
 		}
-		if x == NIL {
-			return NIL
+		return analyzeApplication(x, env, tail)
+	}
+
+	// All other values evaluate to themselves.
+	return func(value.Environment) value.Value {
+		return expr
+	}
+}
+
+// analyzeVariable analyses a reference to a variable.
+func analyzeVariable(x *value.Atom) proc {
+	if x == T || x == NIL || x.IsKeyword() {
+		return func(value.Environment) value.Value {
+			return x
 		}
+	}
+
+	return func(env value.Environment) value.Value {
 		if v, ok := env.Lookup(x.Name); ok {
 			return v
 		}
-		return x // self-quote unassigned variables
-	case *value.Cell:
-		return evalForm(x, env)
+		if SelfQuoting {
+			return x
+		}
+		value.Raise(value.UnboundVariable, "unbound variable: %s", x.Name)
+		panic("not possible")
 	}
-
-	panic(expr)
 }
 
-func evalForm(expr *value.Cell, env value.Environment) value.Value {
-	switch car := expr.Car.(type) {
-	case *value.Atom:
-		switch car.Name {
-		case "quote":
-			return evalQuote(expr)
-		case "cond":
-			return evalCond(expr, env)
-		case "lambda":
-			return evalLambda(expr, env)
-		case "label":
-			return evalLabel(expr, env)
-		case "defun":
-			return evalDefun(expr, env)
+// analyzeApplication analyses the application of a function to a
+// list of arguments.
+func analyzeApplication(expr *value.Cell, env value.Environment, tail bool) proc {
+	fproc := analyze(expr.Car, env, false)
+	aprocs := analyzeArgs(expr, env)
+
+	// A macro defined after this form was analysed is expanded
+	// when the form is evaluated, and the expansion is analysed in
+	// the scope of the form and evaluated in its place. The
+	// analysis is kept until the form calls another macro.
+	scope := env
+	var expanded *value.Macro
+	var expansion proc
+
+	return func(env value.Environment) value.Value {
+		fn := value.Primary(fproc(env))
+
+		if m, ok := fn.(*value.Macro); ok {
+			if m != expanded {
+				expansion = analyze(m.ExpandNested(expr, MaxDepth), scope, tail)
+				expanded = m
+			}
+			return expansion(env)
 		}
-	}
 
-	fn := eval(expr.Car, env)
+		args := make([]value.Value, len(aprocs))
+		for i, aproc := range aprocs {
+			args[i] = value.Primary(aproc(env))
+		}
+
+		if tail {
+			if c, ok := fn.(*closure); ok {
+				return &tailCall{c, args, expr}
+			}
+		}
+		return apply(fn, args, expr)
+	}
+}
 
-	// Evaluate each argument for application to the function.
-	var args []value.Value
+// analyzeArgs analyses each argument of a form for application to a
+// function.
+func analyzeArgs(expr *value.Cell, env value.Environment) []proc {
+	var aprocs []proc
 	if expr.Cdr != NIL {
 		cdr, ok := expr.Cdr.(*value.Cell)
 		if !ok {
-			value.Errorf("The object %s is not a list", expr.Cdr)
+			value.Raise(value.TypeError, "The object %s is not a list", expr.Cdr)
 		}
 		cdr.Walk(func(v value.Value) {
-			args = append(args, eval(v, env))
+			aprocs = append(aprocs, analyze(v, env, false))
 		})
 	}
+	return aprocs
+}
+
+// tailCall is returned by a proc in tail position in place of the
+// value of a call to a closure.
+type tailCall struct {
+	fn   *closure
+	args []value.Value
+	form value.Value
+}
 
-	return invoke(fn, args)
+func (tc *tailCall) String() string {
+	return fmt.Sprintf("#[tail-call %s]", tc.fn)
+}
+
+func (tc *tailCall) Equal(cmp value.Value) value.Value {
+	return NIL
+}
+
+// callStack holds the calls of closures in progress, from the
+// outermost inwards, for backtraces.
+var callStack []value.Frame
+
+// backtrace returns the calls of closures in progress, from the
+// innermost outwards.
+func backtrace() []value.Frame {
+	frames := make([]value.Frame, len(callStack))
+	for i, frame := range callStack {
+		frames[len(frames)-1-i] = frame
+	}
+	return frames
+}
+
+// apply applies a list of arguments to a function, where form is the
+// form of the call, or nil if it is called from Go. Calls to closures
+// in tail position are made by looping, and replace the caller on the
+// call stack.
+func apply(fn value.Value, args []value.Value, form value.Value) value.Value {
+	budget.Step()
+	c, ok := fn.(*closure)
+	if !ok {
+		return invoke(fn, args)
+	}
+
+	n := len(callStack)
+	defer func() { callStack = callStack[:n] }()
+	callStack = append(callStack, value.Frame{})
+	value.AssertDepth(MaxDepth, len(callStack))
+
+	for {
+		callStack[n] = value.Frame{Name: c.name, Args: args, Form: form}
+		v := c.call(args)
+		tc, ok := v.(*tailCall)
+		if !ok {
+			return v
+		}
+		c, args, form = tc.fn, tc.args, tc.form
+		budget.Step()
+	}
 }
 
 // invoke applies a list of arguments to a function.
 func invoke(v value.Value, args []value.Value) value.Value {
 	fn, ok := v.(value.Function)
 	if !ok {
-		value.Errorf("invoke: %s is not a function", v)
+		value.Raise(value.NotAFunction, "invoke: %s is not a function", v)
 	}
 	return fn.Invoke(args)
 }
 
-// evalQuote evaluates the quote special form.
-func evalQuote(expr *value.Cell) value.Value {
+// analyzeQuote analyses the quote special form.
+func analyzeQuote(expr *value.Cell) proc {
 	cdr, ok := expr.Cdr.(*value.Cell)
 	if !ok || cdr.Cdr != NIL {
-		value.Errorf("ill-formed special form: %s", expr)
+		value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+	}
+
+	return func(value.Environment) value.Value {
+		return cdr.Car
+	}
+}
+
+// analyzeQuasiquote analyses the quasiquote special form.
+func analyzeQuasiquote(expr *value.Cell, env value.Environment) proc {
+	cdr, ok := expr.Cdr.(*value.Cell)
+	if !ok || cdr.Cdr != NIL {
+		value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+	}
+
+	return analyzeQuasi(cdr.Car, 1, env)
+}
+
+// analyzeQuasi analyses a quasiquote template at a nesting depth.
+// Unquoted expressions are only evaluated at depth 1; nested
+// quasiquote forms increase the depth, and unquote forms within them
+// decrease it.
+func analyzeQuasi(tmpl value.Value, depth int, env value.Environment) proc {
+	cell, ok := tmpl.(*value.Cell)
+	if !ok {
+		return func(value.Environment) value.Value {
+			return tmpl
+		}
+	}
+
+	// nested returns a proc that rebuilds the form (name x).
+	nested := func(x value.Value, depth int) proc {
+		xproc := analyzeQuasi(x, depth, env)
+		return func(env value.Environment) value.Value {
+			return value.Cons(cell.Car, value.Cons(xproc(env), NIL))
+		}
+	}
+
+	if x, ok := unquoted(cell, "quasiquote"); ok {
+		return nested(x, depth+1)
+	}
+	if x, ok := unquoted(cell, "unquote"); ok {
+		if depth == 1 {
+			xproc := analyze(x, env, false)
+			return func(env value.Environment) value.Value {
+				return value.Primary(xproc(env))
+			}
+		}
+		return nested(x, depth-1)
+	}
+	if x, ok := unquoted(cell, "unquote-splicing"); ok {
+		if depth == 1 {
+			value.Raise(value.ProgramError, ",@ is not within a list: %s", cell)
+		}
+		return nested(x, depth-1)
+	}
+
+	// Each element of the list is analysed, along with the tail
+	// of a dotted list.
+	type element struct {
+		proc   proc
+		splice bool
+	}
+	var elems []element
+	tproc := analyzeQuasi(NIL, depth, env)
+	var next value.Value = cell
+	for next != NIL {
+		c, ok := next.(*value.Cell)
+		if !ok {
+			tproc = analyzeQuasi(next, depth, env)
+			break
+		}
+
+		// A list such as (a . ,b) is read as (a unquote b), and
+		// so the tail is expanded as a whole.
+		if isUnquote(c) {
+			tproc = analyzeQuasi(c, depth, env)
+			break
+		}
+
+		elem := element{proc: nil}
+		if x, ok := c.Car.(*value.Cell); ok && depth == 1 {
+			if spliced, ok := unquoted(x, "unquote-splicing"); ok {
+				elem = element{analyze(spliced, env, false), true}
+			}
+		}
+		if elem.proc == nil {
+			elem.proc = analyzeQuasi(c.Car, depth, env)
+		}
+		elems = append(elems, elem)
+		next = c.Cdr
+	}
+
+	return func(env value.Environment) value.Value {
+		vals := make([]value.Value, len(elems))
+		for i, elem := range elems {
+			vals[i] = value.Primary(elem.proc(env))
+		}
+
+		// The list is built from its tail, so that a final
+		// spliced list is shared rather than copied, and may be
+		// improper.
+		v := tproc(env)
+		for i := len(elems) - 1; i >= 0; i-- {
+			if !elems[i].splice {
+				v = value.Cons(vals[i], v)
+				continue
+			}
+			if v == NIL && i == len(elems)-1 {
+				v = vals[i]
+				continue
+			}
+			if vals[i] == NIL {
+				continue
+			}
+			list, ok := vals[i].(*value.Cell)
+			if !ok {
+				value.Raise(value.TypeError, "The object %s is not a list", vals[i])
+			}
+			var spliced []value.Value
+			list.Walk(func(x value.Value) {
+				spliced = append(spliced, x)
+			})
+			for j := len(spliced) - 1; j >= 0; j-- {
+				v = value.Cons(spliced[j], v)
+			}
+		}
+		return v
+	}
+}
+
+// isUnquote reports whether a cell is an unquote or unquote-splicing
+// form.
+func isUnquote(c *value.Cell) bool {
+	_, ok := unquoted(c, "unquote")
+	if !ok {
+		_, ok = unquoted(c, "unquote-splicing")
 	}
+	return ok
+}
 
-	return cdr.Car
+// unquoted returns x if a cell is the form (name x).
+func unquoted(c *value.Cell, name string) (value.Value, bool) {
+	sym, ok := c.Car.(*value.Atom)
+	if !ok || sym.Name != name {
+		return nil, false
+	}
+	cdr, ok := c.Cdr.(*value.Cell)
+	if !ok || cdr.Cdr != NIL {
+		return nil, false
+	}
+	return cdr.Car, true
 }
 
-// evalCond evaluates the cond special form.
-func evalCond(expr *value.Cell, env value.Environment) value.Value {
+// analyzeCond analyses the cond special form.
+func analyzeCond(expr *value.Cell, env value.Environment, tail bool) proc {
 	checkExpr := func(ok bool) {
 		if !ok {
-			value.Errorf("ill-formed special form: %s", expr)
+			value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
 		}
 	}
 
+	type clause struct {
+		test proc
+		body proc
+	}
+	var clauses []clause
+
 	next := expr
 	for next.Cdr != NIL {
 		v, ok := next.Cdr.(*value.Cell)
 		checkExpr(ok)
 		next = v
 
-		clause, ok := next.Car.(*value.Cell)
+		c, ok := next.Car.(*value.Cell)
+		checkExpr(ok)
+		body, ok := c.Cdr.(*value.Cell)
 		checkExpr(ok)
 
-		// if caadr is true, then we want to return the
-		// evaluation of the cdadr
-		if eval(clause.Car, env) == T {
-			body, ok := clause.Cdr.(*value.Cell)
-			checkExpr(ok)
-			return evalProgn(body, env)
+		clauses = append(clauses, clause{
+			test: analyze(c.Car, env, false),
+			body: analyzeBody(body, env, tail),
+		})
+	}
+
+	return func(env value.Environment) value.Value {
+		for _, c := range clauses {
+			// if caadr is true, then we want to return the
+			// evaluation of the cdadr
+			if value.Primary(c.test(env)) != NIL {
+				return c.body(env)
+			}
 		}
+		return NIL
 	}
-	return NIL
 }
 
-// evalProgn evaluates a proper list of values, returning the last
-// value.
-func evalProgn(v value.Value, env value.Environment) value.Value {
-	var last value.Value = unspecified
-	if v == NIL {
-		return last
+// analyzeIf analyses the if special form.
+func analyzeIf(expr *value.Cell, env value.Environment, tail bool) proc {
+	checkExpr := func(ok bool) {
+		if !ok {
+			value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+		}
+	}
+
+	// (if test then [else])
+	cdr, ok := expr.Cdr.(*value.Cell)
+	checkExpr(ok)
+	cddr, ok := cdr.Cdr.(*value.Cell)
+	checkExpr(ok)
+	var otherwise value.Value = NIL
+	if cddr.Cdr != NIL {
+		cdddr, ok := cddr.Cdr.(*value.Cell)
+		checkExpr(ok && cdddr.Cdr == NIL)
+		otherwise = cdddr.Car
+	}
+
+	test := analyze(cdr.Car, env, false)
+	then := analyze(cddr.Car, env, tail)
+	alt := analyze(otherwise, env, tail)
+	return func(env value.Environment) value.Value {
+		if value.Primary(test(env)) != NIL {
+			return then(env)
+		}
+		return alt(env)
 	}
+}
 
-	list, ok := v.(*value.Cell)
+// analyzeAnd analyses the and special form, which is nil as soon as a
+// form is false, or otherwise the value of the last form.
+func analyzeAnd(expr *value.Cell, env value.Environment, tail bool) proc {
+	procs := analyzeForms(expr, env, tail)
+	if len(procs) == 0 {
+		return analyzeVariable(T)
+	}
+
+	return func(env value.Environment) value.Value {
+		last := len(procs) - 1
+		for _, p := range procs[:last] {
+			if value.Primary(p(env)) == NIL {
+				return NIL
+			}
+		}
+		return procs[last](env)
+	}
+}
+
+// analyzeOr analyses the or special form, which is the value of the
+// first form that is true.
+func analyzeOr(expr *value.Cell, env value.Environment, tail bool) proc {
+	procs := analyzeForms(expr, env, tail)
+	if len(procs) == 0 {
+		return analyzeVariable(NIL)
+	}
+
+	return func(env value.Environment) value.Value {
+		last := len(procs) - 1
+		for _, p := range procs[:last] {
+			if v := value.Primary(p(env)); v != NIL {
+				return v
+			}
+		}
+		return procs[last](env)
+	}
+}
+
+// analyzeForms analyses the forms following the operator of a special
+// form. Only the last form may be in tail position.
+func analyzeForms(expr *value.Cell, env value.Environment, tail bool) []proc {
+	var procs []proc
+	next := expr.Cdr
+	for next != NIL {
+		cell, ok := next.(*value.Cell)
+		if !ok {
+			value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+		}
+		procs = append(procs, analyze(cell.Car, env, tail && cell.Cdr == NIL))
+		next = cell.Cdr
+	}
+	return procs
+}
+
+// analyzeWhen analyses the when special form, or the unless special
+// form if want is false.
+func analyzeWhen(expr *value.Cell, env value.Environment, tail bool, want bool) proc {
+	// (when test body1 ... bodyN)
+	cdr, ok := expr.Cdr.(*value.Cell)
 	if !ok {
-		value.Errorf("implicit progn must be a list: %s", v)
+		value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+	}
+
+	test := analyze(cdr.Car, env, false)
+	body := analyzeProgn(cdr.Cdr, env, tail)
+	return func(env value.Environment) value.Value {
+		if (value.Primary(test(env)) != NIL) != want {
+			return NIL
+		}
+		return body(env)
 	}
+}
 
-	list.Walk(func(v value.Value) {
-		last = eval(v, env)
-	})
-	return last
+// analyzeProgn analyses an implicit progn. An empty progn is nil.
+func analyzeProgn(forms value.Value, env value.Environment, tail bool) proc {
+	if forms == NIL {
+		return analyzeVariable(NIL)
+	}
+	list, ok := forms.(*value.Cell)
+	if !ok {
+		value.Raise(value.ProgramError, "implicit progn must be a list: %s", forms)
+	}
+	return analyzeBody(list, env, tail)
 }
 
-func evalLambda(expr *value.Cell, env value.Environment) value.Value {
+// analyzeUnwindProtect analyses the unwind-protect special form. The
+// cleanup forms are evaluated however the protected form is exited,
+// including by an error.
+func analyzeUnwindProtect(expr *value.Cell, env value.Environment) proc {
+	// (unwind-protect protected cleanup1 ... cleanupN)
+	cdr, ok := expr.Cdr.(*value.Cell)
+	if !ok {
+		value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+	}
+	protected := analyze(cdr.Car, env, false)
+	cleanup := analyzeProgn(cdr.Cdr, env, false)
+
+	return func(env value.Environment) value.Value {
+		defer func() {
+			// An evaluation that has exhausted its budget
+			// is stopped without evaluating the cleanup
+			// forms, which would not be permitted a step.
+			if !budget.Exhausted() {
+				cleanup(env)
+			}
+		}()
+		return protected(env)
+	}
+}
+
+// analyzeMultipleValueCall analyses the multiple-value-call special
+// form, which applies a function to every value of each form.
+func analyzeMultipleValueCall(expr *value.Cell, env value.Environment, tail bool) proc {
+	// (multiple-value-call function form1 ... formN)
+	cdr, ok := expr.Cdr.(*value.Cell)
+	if !ok {
+		value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+	}
+	fproc := analyze(cdr.Car, env, false)
+	procs := analyzeForms(cdr, env, false)
+
+	return func(env value.Environment) value.Value {
+		fn := value.Primary(fproc(env))
+		var args []value.Value
+		for _, p := range procs {
+			args = append(args, value.MultipleValues(p(env))...)
+		}
+
+		if tail {
+			if c, ok := fn.(*closure); ok {
+				return &tailCall{c, args, expr}
+			}
+		}
+		return apply(fn, args, expr)
+	}
+}
+
+// analyzeBody analyses a proper list of forms, which are evaluated in
+// order, returning the value of the last.
+func analyzeBody(list *value.Cell, env value.Environment, tail bool) proc {
+	var procs []proc
+	for {
+		next, ok := list.Cdr.(*value.Cell)
+		if !ok {
+			break
+		}
+		procs = append(procs, analyze(list.Car, env, false))
+		list = next
+	}
+	if list.Cdr != NIL {
+		value.Raise(value.ProgramError, "cannot evaluate an improper list: %s", list)
+	}
+	last := analyze(list.Car, env, tail)
+
+	if len(procs) == 0 {
+		return last
+	}
+	return func(env value.Environment) value.Value {
+		for _, p := range procs {
+			p(env)
+		}
+		return last(env)
+	}
+}
+
+// analyzeLet analyses the let special form.
+func analyzeLet(expr *value.Cell, env value.Environment, tail bool) proc {
+	names, initExprs, bodyExpr := parseLet(expr)
+	inits := make([]proc, len(initExprs))
+	for i, init := range initExprs {
+		inits[i] = analyze(init, env, false)
+	}
+	special := specialNames(names)
+	body := analyzeLetBody(names, bodyExpr, env, tail)
+
+	return func(env value.Environment) value.Value {
+		// Initial values are evaluated before any are bound.
+		vals := make([]value.Value, len(inits))
+		for i, init := range inits {
+			vals[i] = value.Primary(init(env))
+		}
+
+		var bindings dynamicBindings
+		defer bindings.unbind()
+
+		extEnv := value.NewEnv(env)
+		for i, name := range names {
+			if special[name] {
+				bindings.bind(name, vals[i])
+				continue
+			}
+			extEnv.Define(name, vals[i])
+		}
+		return body(extEnv)
+	}
+}
+
+// analyzeLetStar analyses the let* special form, where each initial
+// value may refer to the variables bound before it.
+func analyzeLetStar(expr *value.Cell, env value.Environment, tail bool) proc {
+	names, initExprs, bodyExpr := parseLet(expr)
+	inits := make([]proc, len(initExprs))
+	for i, init := range initExprs {
+		inits[i] = analyze(init, lexicalEnv(env, names[:i]), false)
+	}
+	special := specialNames(names)
+	body := analyzeLetBody(names, bodyExpr, env, tail)
+
+	return func(env value.Environment) value.Value {
+		var bindings dynamicBindings
+		defer bindings.unbind()
+
+		extEnv := value.NewEnv(env)
+		for i, name := range names {
+			v := value.Primary(inits[i](extEnv))
+			if special[name] {
+				bindings.bind(name, v)
+				continue
+			}
+			extEnv.Define(name, v)
+		}
+		return body(extEnv)
+	}
+}
+
+// analyzeLetrec analyses the letrec special form, where each initial
+// value may refer to any of the variables, allowing the definition of
+// mutually recursive functions.
+func analyzeLetrec(expr *value.Cell, env value.Environment, tail bool) proc {
+	names, initExprs, bodyExpr := parseLet(expr)
+	scope := lexicalEnv(env, names)
+	inits := make([]proc, len(initExprs))
+	for i, init := range initExprs {
+		inits[i] = analyze(init, scope, false)
+	}
+	special := specialNames(names)
+	body := analyzeLetBody(names, bodyExpr, env, tail)
+
+	return func(env value.Environment) value.Value {
+		var bindings dynamicBindings
+		defer bindings.unbind()
+
+		extEnv := value.NewEnv(env)
+		for _, name := range names {
+			if !special[name] {
+				extEnv.Define(name, unassigned)
+			}
+		}
+		for i, name := range names {
+			v := value.Primary(inits[i](extEnv))
+			if special[name] {
+				bindings.bind(name, v)
+				continue
+			}
+			extEnv.Update(name, v)
+		}
+		return body(extEnv)
+	}
+}
+
+// specialNames returns the set of the names of special variables, or
+// nil if there are none.
+func specialNames(names []string) map[string]bool {
+	var special map[string]bool
+	for _, name := range names {
+		if value.IsSpecial(name) {
+			if special == nil {
+				special = map[string]bool{}
+			}
+			special[name] = true
+		}
+	}
+	return special
+}
+
+// dynamicBindings are the bindings of special variables made by a
+// form, which are in effect until the form is exited.
+type dynamicBindings []*value.DynamicBinding
+
+// bind enters a binding of a special variable. Special variables are
+// bound globally, as they are by the compiler, so that a binding is
+// never made to a lexical variable of the same name.
+func (bs *dynamicBindings) bind(name string, v value.Value) {
+	b := value.NewDynamicBinding(UserEnvironment, name, v)
+	b.Swap()
+	*bs = append(*bs, b)
+}
+
+// unbind leaves the bindings, from the innermost outwards.
+func (bs *dynamicBindings) unbind() {
+	for i := len(*bs) - 1; i >= 0; i-- {
+		(*bs)[i].Swap()
+	}
+}
+
+// lexicalEnv returns an environment for analysing the forms within
+// the scope of lexical variables, where each variable shadows a macro
+// of the same name, as it does when compiled. Special variables are
+// bound dynamically, and so do not shadow macros. The variables are
+// not bound to their values until the forms are evaluated.
+func lexicalEnv(env value.Environment, names []string) value.Environment {
+	extEnv := value.NewEnv(env)
+	for _, name := range names {
+		if !value.IsSpecial(name) {
+			extEnv.Define(name, unassigned)
+		}
+	}
+	return extEnv
+}
+
+// analyzeLetBody analyses the body of a let special form, within the
+// scope of its variables. The body is not in tail position if a
+// special variable is bound, as the binding is in effect until it
+// returns.
+func analyzeLetBody(names []string, body *value.Cell, env value.Environment, tail bool) proc {
+	return analyzeBody(body, lexicalEnv(env, names), tail && specialNames(names) == nil)
+}
+
+// parseLet destructures a let special form into the names of its
+// variables, their initial values and its body. A binding may be
+// written as name, (name) or (name init); the initial value is nil if
+// omitted.
+func parseLet(expr *value.Cell) ([]string, []value.Value, *value.Cell) {
 	checkExpr := func(ok bool) {
 		if !ok {
-			value.Errorf("ill-formed special form: %s", expr)
+			value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+		}
+	}
+
+	// (cadr (let ((var1 init1) ... (varN initN)) body1 ... bodyN))
+	cdr, ok := expr.Cdr.(*value.Cell)
+	checkExpr(ok)
+
+	// (cddr (let bindings body1 ... bodyN))
+	body, ok := cdr.Cdr.(*value.Cell)
+	checkExpr(ok)
+
+	var names []string
+	var inits []value.Value
+	if cdr.Car != NIL {
+		bindings, ok := cdr.Car.(*value.Cell)
+		checkExpr(ok)
+
+		bindings.Walk(func(v value.Value) {
+			var init value.Value = NIL
+			if binding, ok := v.(*value.Cell); ok {
+				v = binding.Car
+				if binding.Cdr != NIL {
+					rest, ok := binding.Cdr.(*value.Cell)
+					checkExpr(ok && rest.Cdr == NIL)
+					init = rest.Car
+				}
+			}
+			name, ok := v.(*value.Atom)
+			checkExpr(ok)
+
+			names = append(names, name.Name)
+			inits = append(inits, init)
+		})
+	}
+
+	return names, inits, body
+}
+
+// analyzeSetq analyses the setq special form, which assigns the value
+// of each expression to the variable preceding it, in turn.
+func analyzeSetq(expr *value.Cell, env value.Environment) proc {
+	checkExpr := func(ok bool) {
+		if !ok {
+			value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+		}
+	}
+
+	// (setq var1 form1 ... varN formN)
+	var names []*value.Atom
+	var procs []proc
+	next := expr.Cdr
+	for next != NIL {
+		pair, ok := next.(*value.Cell)
+		checkExpr(ok)
+		name, ok := pair.Car.(*value.Atom)
+		checkExpr(ok)
+		rest, ok := pair.Cdr.(*value.Cell)
+		checkExpr(ok)
+
+		names = append(names, name)
+		procs = append(procs, analyze(rest.Car, env, false))
+		next = rest.Cdr
+	}
+
+	return func(env value.Environment) value.Value {
+		var last value.Value = NIL
+		for i, p := range procs {
+			last = value.Primary(p(env))
+			assign(env, names[i], last)
+		}
+		return last
+	}
+}
+
+// analyzeSet analyses the set! special form, which assigns the value
+// of an expression to a variable.
+func analyzeSet(expr *value.Cell, env value.Environment) proc {
+	checkExpr := func(ok bool) {
+		if !ok {
+			value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+		}
+	}
+
+	// (set! var form)
+	cdr, ok := expr.Cdr.(*value.Cell)
+	checkExpr(ok)
+	name, ok := cdr.Car.(*value.Atom)
+	checkExpr(ok)
+	cddr, ok := cdr.Cdr.(*value.Cell)
+	checkExpr(ok && cddr.Cdr == NIL)
+
+	vproc := analyze(cddr.Car, env, false)
+	return func(env value.Environment) value.Value {
+		v := value.Primary(vproc(env))
+		assign(env, name, v)
+		return v
+	}
+}
+
+// assign updates the existing binding of a variable, raising an error
+// if the variable is unbound.
+func assign(env value.Environment, sym *value.Atom, v value.Value) {
+	if sym == T || sym == NIL || sym.IsKeyword() {
+		value.Raise(value.ProgramError, "cannot assign to constant: %s", sym)
+	}
+	if err := env.Update(sym.Name, v); err != nil {
+		value.Raise(value.UnboundVariable, "%s", err)
+	}
+}
+
+// analyzeLambda analyses the lambda special form, naming the function.
+func analyzeLambda(expr *value.Cell, env value.Environment, name string) proc {
+	checkExpr := func(ok bool) {
+		if !ok {
+			value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
 		}
 	}
 
@@ -183,14 +1053,14 @@ func evalLambda(expr *value.Cell, env value.Environment) value.Value {
 	body, ok := cdr.Cdr.(*value.Cell)
 	checkExpr(ok)
 
-	return makeFunction(cdr.Car, body, env)
+	return analyzeFunction(name, cdr.Car, body, env)
 }
 
-// evalLabel evaluates the label special form.
-func evalLabel(expr *value.Cell, env value.Environment) value.Value {
+// analyzeLabel analyses the label special form.
+func analyzeLabel(expr *value.Cell, env value.Environment) proc {
 	checkExpr := func(ok bool) {
 		if !ok {
-			value.Errorf("ill-formed special form: %s", expr)
+			value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
 		}
 	}
 
@@ -205,24 +1075,99 @@ func evalLabel(expr *value.Cell, env value.Environment) value.Value {
 	checkExpr(ok && cddr.Cdr == NIL)
 	caddr, ok := cddr.Car.(*value.Cell)
 	checkExpr(ok)
+	lambda := analyzeLambda(caddr, lexicalEnv(env, []string{label.Name}), label.Name)
 
-	// Evaluate lambda in an environment where it is able to
-	// reference the name defined by the label special form.
-	extEnv := value.NewEnv(env)
-	extEnv.Define(label.Name, unassigned)
-	fn := evalLambda(caddr, extEnv)
+	return func(env value.Environment) value.Value {
+		// Evaluate lambda in an environment where it is able to
+		// reference the name defined by the label special form.
+		extEnv := value.NewEnv(env)
+		extEnv.Define(label.Name, unassigned)
+		fn := lambda(extEnv)
+
+		// Update the binding to the newly created function.
+		extEnv.Update(label.Name, fn)
+
+		return fn
+	}
+}
+
+// analyzeDefun analyses the definition of a permanent function.
+func analyzeDefun(expr *value.Cell, env value.Environment) proc {
+	symbol, argExpr, body := parseDefinition(expr)
+	lambda := analyzeFunction(symbol.Name, argExpr, body, env)
+
+	return func(env value.Environment) value.Value {
+		// Functions are always defined globally, even when the
+		// defun form is within a function.
+		UserEnvironment.Define(symbol.Name, lambda(env))
 
-	// Update the binding to the newly created function.
-	extEnv.Update(label.Name, fn)
+		return symbol
+	}
+}
+
+// analyzeDefmacro analyses the definition of a permanent macro.
+func analyzeDefmacro(expr *value.Cell, env value.Environment) proc {
+	symbol, argExpr, body := parseDefinition(expr)
+	lambda := analyzeFunction(symbol.Name, argExpr, body, env)
 
-	return fn
+	return func(env value.Environment) value.Value {
+		UserEnvironment.Define(symbol.Name, &value.Macro{
+			Name:     symbol.Name,
+			Expander: lambda(env).(value.Function),
+		})
+
+		return symbol
+	}
 }
 
-// evalDefun defines a function permanently.
-func evalDefun(expr *value.Cell, env value.Environment) *value.Atom {
+// analyzeDefvar analyses the definition of a special variable by the
+// defvar special form, or by defparameter if always is true. The
+// variable is declared special as soon as the form is analysed, so
+// that bindings of it analysed later are dynamic. Defvar only
+// evaluates the value if the variable is unbound, and binds nil if
+// there is no value.
+func analyzeDefvar(expr *value.Cell, env value.Environment, always bool) proc {
 	checkExpr := func(ok bool) {
 		if !ok {
-			value.Errorf("ill-formed special form: %s", expr)
+			value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
+		}
+	}
+
+	// (defvar name [value [documentation]])
+	// (defparameter name value [documentation])
+	cdr, ok := expr.Cdr.(*value.Cell)
+	checkExpr(ok)
+	symbol, ok := cdr.Car.(*value.Atom)
+	checkExpr(ok && symbol != T && symbol != NIL && !symbol.IsKeyword())
+	var init value.Value = NIL
+	if cdr.Cdr != NIL {
+		cddr, ok := cdr.Cdr.(*value.Cell)
+		checkExpr(ok)
+		if cddr.Cdr != NIL {
+			doc, ok := cddr.Cdr.(*value.Cell)
+			checkExpr(ok && doc.Cdr == NIL)
+		}
+		init = cddr.Car
+	} else {
+		checkExpr(!always)
+	}
+
+	value.DeclareSpecial(symbol.Name)
+	vproc := analyze(init, env, false)
+	return func(env value.Environment) value.Value {
+		if _, ok := UserEnvironment.Lookup(symbol.Name); always || !ok {
+			UserEnvironment.Define(symbol.Name, value.Primary(vproc(env)))
+		}
+		return symbol
+	}
+}
+
+// parseDefinition destructures a definition special form, such as
+// defun or defmacro, into its name, lambda list and body.
+func parseDefinition(expr *value.Cell) (*value.Atom, value.Value, *value.Cell) {
+	checkExpr := func(ok bool) {
+		if !ok {
+			value.Raise(value.IllFormedSpecialForm, "ill-formed special form: %s", expr)
 		}
 	}
 
@@ -240,50 +1185,73 @@ func evalDefun(expr *value.Cell, env value.Environment) *value.Atom {
 	body, ok := cddr.Cdr.(*value.Cell)
 	checkExpr(ok)
 
-	// By defining in the current environment, we add a permanent
-	// function, but don't need to find the toplevel. I think this
-	// differs from a typical Lisp, but falls within McCarthy's
-	// described behaviour.
-	env.Define(symbol.Name, unassigned)
-	fn := makeFunction(cddr.Car, body, env)
-	env.Update(symbol.Name, fn)
+	return symbol, cddr.Car, body
+}
 
-	return symbol
+// analyzeFunction analyses a lambda list and body, returning a proc
+// that creates a closure. The body is analysed once, when the
+// function is defined, rather than each time it is called.
+func analyzeFunction(name string, argExpr value.Value, bodyExpr *value.Cell, env value.Environment) proc {
+	params, scope := parseLambdaList(argExpr, env)
+	doc, bodyExpr := compile.ParseDocumentation(bodyExpr)
+	body := analyzeBody(bodyExpr, scope, params.special == nil)
+
+	return func(env value.Environment) value.Value {
+		return &closure{name: name, params: params, doc: doc, body: body, env: env}
+	}
 }
 
-// makeFunction creates a new function from the lambda special form.
-func makeFunction(argExpr value.Value, bodyExpr *value.Cell, env value.Environment) value.Function {
-	var vars []string
-	if argExpr != NIL {
-		argCell, ok := argExpr.(*value.Cell)
-		if !ok {
-			value.Errorf("The object %s is not a list", argExpr)
-		}
+// closure is a function created by the lambda special form.
+type closure struct {
+	name   string // empty if anonymous
+	params *lambdaList
+	doc    string            // documentation string, if any
+	body   proc              // implicit progn, in tail position unless a parameter is special
+	env    value.Environment // environment of definition
+}
 
-		argCell.Walk(func(v value.Value) {
-			atom, ok := v.(*value.Atom)
-			if !ok {
-				value.Errorf("The object %s is not a symbol", v)
-			}
+func (c *closure) String() string {
+	return value.CompoundString(c.name, c.params.Source)
+}
 
-			vars = append(vars, atom.Name)
-		})
+// Equal implements the Value interface, and returns T for the same
+// closure.
+func (c *closure) Equal(cmp value.Value) value.Value {
+	if x, ok := cmp.(*closure); ok && c == x {
+		return T
 	}
+	return NIL
+}
 
-	fn := func(args []value.Value) value.Value {
-		// Arguments are bound in a new environment.
-		extEnv := value.NewEnv(env)
-		for i, name := range vars {
-			extEnv.Define(name, args[i])
-		}
+// Name implements the NamedFunction interface.
+func (c *closure) Name() string {
+	return c.name
+}
 
-		// Body is evaluated in an implicit progn.
-		var last value.Value = unspecified
-		bodyExpr.Walk(func(v value.Value) {
-			last = eval(v, extEnv)
-		})
-		return last
+// LambdaList implements the NamedFunction interface.
+func (c *closure) LambdaList() value.Value {
+	return c.params.Source
+}
+
+// Documentation implements the NamedFunction interface.
+func (c *closure) Documentation() string {
+	return c.doc
+}
+
+// Invoke implements the Function interface.
+func (c *closure) Invoke(args []value.Value) value.Value {
+	return apply(c, args, nil)
+}
+
+// call evaluates the closure's body in a new environment where its
+// parameters are bound to the arguments. The bindings of special
+// parameters are in effect until the body returns.
+func (c *closure) call(args []value.Value) value.Value {
+	if c.params.special == nil {
+		return c.body(c.params.bind(c.name, c.env, args, nil))
 	}
 
-	return value.FuncX(len(vars), fn)
+	var bindings dynamicBindings
+	defer bindings.unbind()
+	return c.body(c.params.bind(c.name, c.env, args, &bindings))
 }
@@ -18,6 +18,18 @@ func (m stringMatcher) MatchString(s string) bool {
 	return string(m) == s
 }
 
+// forEachEvaluator runs a test once with each evaluator as the
+// default.
+func forEachEvaluator(t *testing.T, f func(t *testing.T)) {
+	for _, e := range []Evaluator{Analyzer, Compiler} {
+		t.Run(e.String(), func(t *testing.T) {
+			defer func(old Evaluator) { DefaultEvaluator = old }(DefaultEvaluator)
+			DefaultEvaluator = e
+			f(t)
+		})
+	}
+}
+
 func TestEval(t *testing.T) {
 	testCases := []struct {
 		expr string      // expression to evalute
@@ -31,8 +43,99 @@ func TestEval(t *testing.T) {
 		{"(atom t)", "t"},
 		{"(atom nil)", "t"},
 		{"(atom ())", "t"},
-		{"(atom (cons 1 2))", "nil"},
-		{"(atom (cons 1 (cons 2 nil)))", "nil"},
+		{"(atom (cons (quote 1) (quote 2)))", "nil"},
+		{"(atom (cons (quote 1) (cons (quote 2) nil)))", "nil"},
+
+		{"(atom 1)", "t"},
+
+		// Integers
+		{"42", "42"},
+		{"(quote -42)", "-42"},
+		{"(list (+) (+ 1) (+ 1 2 3) (- 1) (- 10 1 2) (*) (* 2 3 4))", "(0 1 6 -1 7 1 24)"},
+		{"(* 99999999999 99999999999 99999999999)", "999999999970000000000299999999999"},
+		{"(- (* 99999999999 99999999999) (* 99999999999 99999999999))", "0"},
+		{"(+ 9223372036854775807 1)", "9223372036854775808"},
+		{"(list (quotient 7 2) (quotient -7 2) (remainder 7 2) (remainder -7 2) (mod -7 2) (mod 7 -2))", "(3 -3 1 -1 1 -1)"},
+		{"(quotient 1 0)", "#[error: quotient: division by zero]"},
+		{"(condition-type (ignore-errors (lambda () (mod 1 0))))", "division-by-zero"},
+		{"(list (= 1 1 1) (= 1 2) (< 1 2 3) (< 1 3 2) (> 3 2 1) (<= 1 1 2) (>= 2 2 3))", "(t nil t nil t t nil)"},
+		{"(list (zerop 0) (zerop 1) (abs -5) (abs 5))", "(t nil 5 5)"},
+		{"(list (expt 2 10) (expt 2 100) (expt 0 0))", "(1024 1267650600228229401496703205376 1)"},
+		{"(list (expt 2 -2) (expt 2/3 3) (expt 2/3 -2) (expt 4 1/2) (expt 2.0 3))", "(1/4 8/27 9/4 2.0 8.0)"},
+		{"(expt 0 -1)", "#[error: expt: division by zero]"},
+		{"(list (gcd) (gcd 12 -18) (gcd 12 18 8) (lcm) (lcm 4 6) (lcm 4 0))", "(0 6 2 1 12 0)"},
+		{"(list (equal 100000000000000000000 (* 10000000000 10000000000)) (eq 1 1) (equal 1 2))", "(t t nil)"},
+		{"(+ 1 (quote a))", "#[error: +: a is not a number]"},
+		{"(gcd 1/2)", "#[error: gcd: 1/2 is not an integer]"},
+		{"(quotient 1.5 1)", "#[error: quotient: 1.5 is not an integer]"},
+		{"(condition-type (ignore-errors (lambda () (< (quote a)))))", "not-a-number"},
+		{"(-)", "#[error: -: called with 0 arguments; requires at least 1 argument]"},
+
+		// Rationals and floats
+		{"(list 3/4 -6/8 4/2 1.5 1.5e10 -2.5E-3 .5 1e3)", "(3/4 -3/4 2 1.5 1.5e+10 -0.0025 0.5 1000.0)"},
+		{"(list (/ 1 2) (/ 6 3) (/ 2) (/ 1 2 3) (/ 3/4 1/4) (/ 1.0 4))", "(1/2 2 1/2 1/6 3 0.25)"},
+		{"(/ 1 0)", "#[error: /: division by zero]"},
+		{"(/ 1.0 0.0)", "#[error: /: division by zero]"},
+		{"(list (+ 1/2 1/2) (+ 1/3 1) (* 2/3 3/2) (- 1/2 1) (+ 1/2 0.5) (* 2 0.5))", "(1 4/3 1 -1/2 1.0 1.0)"},
+		{"(list (= 1/2 0.5) (< 1/3 0.34 1) (= 1 1.0) (equal 1 1.0) (equal 1/2 (/ 2 4)) (equal 0.5 0.5))", "(t t t nil t t)"},
+		{"(list (float 1/4) (float 3) (float 1.5))", "(0.25 3.0 1.5)"},
+		{"(list (numerator 6/8) (denominator 6/8) (numerator -3) (denominator -3))", "(3 4 -3 1)"},
+		{"(numerator 0.5)", "#[error: numerator: 0.5 is not a rational]"},
+		{"(multiple-value-list (floor 7 2))", "(3 1)"},
+		{"(multiple-value-list (floor -7 2))", "(-4 1)"},
+		{"(multiple-value-list (ceiling 7 2))", "(4 -1)"},
+		{"(multiple-value-list (truncate -7 2))", "(-3 -1)"},
+		{"(list (round 5/2) (round 7/2) (round -5/2) (round 2.5) (round 3.5) (round 2.6))", "(2 4 -2 2 4 3)"},
+		{"(multiple-value-list (floor 7/2))", "(3 1/2)"},
+		{"(multiple-value-list (floor 5.5 2))", "(2 1.5)"},
+		{"(list (floor 1.0e20) (abs -1/2) (abs -1.5) (zerop 0.0) (zerop 1/2))", "(100000000000000000000 1/2 1.5 t nil)"},
+		{"(floor 1 0)", "#[error: floor: division by zero]"},
+		{"(floor 1.0 1e-320)", "#[error: floor: #[float +Inf] cannot be rounded to an integer]"},
+		{"(list (- 0.0) (- 1.5) 1. -2.)", "(-0.0 -1.5 1 -2)"},
+		{"(* 1e308 10)", "#[error: *: floating point overflow]"},
+		{"(float (expt 10 400))", "#[error: float: floating point overflow]"},
+		{"(expt -8.0 0.5)", "#[error: expt: undefined result]"},
+
+		{`"hello"`, `"hello"`},
+		{`(quote ("a" "b\\c"))`, `("a" "b\\c")`},
+		{`(list (string-length "") (string-length "abc") (string-length "λx"))`, "(0 3 2)"},
+		{`(list (substring "hello" 1 3) (substring "hello" 2) (substring "λxy" 1) (substring "abc" 3))`, `("el" "llo" "xy" "")`},
+		{`(substring "hello" 3 1)`, "#[error: substring: start 3 is greater than end 1]"},
+		{`(substring "abc" 4 1)`, "#[error: substring: index 4 is out of range]"},
+		{`(substring "abc" 0 4)`, "#[error: substring: index 4 is out of range]"},
+		{`(substring "abc" (quote a))`, "#[error: substring: a is not an integer]"},
+		{`(list (string-append) (string-append "a") (string-append "a" "" "bc"))`, `("" "a" "abc")`},
+		{`(string-append "a" (quote b))`, "#[error: string-append: b is not a string]"},
+		{`(list (string= "abc" "abc") (string= "abc" "abd") (equal "abc" "abc") (equal "a" (quote a)))`, "(t nil t nil)"},
+		{`(list (string< "abc" "abd") (string< "ab" "abc") (string< "b" "abc") (string< "a" "a"))`, "(t t nil nil)"},
+		{`(list (string= "a") (string= "a" "a" "a") (string= "a" "a" "b") (string< "a" "b" "c") (string< "a" "c" "b"))`, "(t t nil t nil)"},
+		{`(list (string> "c" "b" "a") (string<= "a" "a" "b") (string>= "b" "b" "c") (string> "λ" "z"))`, "(t t nil t)"},
+		{`(string= "a" "a" (quote a))`, "#[error: string=: a is not a string]"},
+		{`(string<)`, "#[error: string<: called with 0 arguments; requires at least 1 argument]"},
+		{`(string-upcase "héllo λ")`, `"HÉLLO Λ"`},
+		{`(list (string-search "lo" "hello") (string-search "" "abc") (string-search "x" "abc") (string-search "y" "λxy"))`, "(3 0 nil 2)"},
+		{`(list (string->symbol "abc") (eq (string->symbol "car") (quote car)) (symbol->string (quote abc)))`, `(abc t "abc")`},
+		{`(symbol->string "abc")`, `#[error: symbol->string: "abc" is not a symbol]`},
+		{`(list (string->list "aλ") (string->list ""))`, `((#\a #\λ) nil)`},
+		{`(list (number->string 42) (number->string -3/4) (number->string 1.5))`, `("42" "-3/4" "1.5")`},
+		{`(number->string "42")`, `#[error: number->string: "42" is not a number]`},
+		{`(list (atom "a") (atom #\a) (car (string->list "abc")))`, `(t t #\a)`},
+		{`(error "no such file:" "a b" (quote c) "\"d\"")`, `#[error: no such file: a b c "d"]`},
+		{`(handler-case (string-length (quote a)) (not-a-string (c) (list (condition-type c) c)))`,
+			"(not-a-string #[error: string-length: a is not a string])"},
+
+		{`(list #\a #\A #\( #\space #\newline #\x41 #\x #\λ #\x3bb)`, `(#\a #\A #\( #\space #\newline #\A #\x #\λ #\λ)`},
+		{`(list (char->integer #\a) (char->integer #\λ) (integer->char 97) (integer->char 955) (integer->char 8232))`, `(97 955 #\a #\λ #\x2028)`},
+		{`(integer->char -1)`, "#[error: integer->char: -1 is not a code point]"},
+		{`(integer->char 55296)`, "#[error: integer->char: 55296 is not a code point]"},
+		{`(integer->char 4294967393)`, "#[error: integer->char: 4294967393 is not a code point]"},
+		{`(char->integer "a")`, `#[error: char->integer: "a" is not a character]`},
+		{`(list (char-upcase #\a) (char-upcase #\λ) (char-upcase #\1))`, `(#\A #\Λ #\1)`},
+		{`(list (char-alphabetic? #\a) (char-alphabetic? #\λ) (char-alphabetic? #\1) (char-alphabetic? #\space))`, "(t t nil nil)"},
+		{`(list (char-whitespace? #\space) (char-whitespace? #\tab) (char-whitespace? #\x3000) (char-whitespace? #\a))`, "(t t t nil)"},
+		{`(list (char= #\a #\a) (char= #\a #\b) (char< #\a #\b #\c) (char< #\a #\c #\b) (char> #\λ #\a))`, "(t nil t nil t)"},
+		{`(list (char<= #\a #\a #\b) (char>= #\b #\a #\b) (equal #\a #\a) (equal #\a "a"))`, "(t nil t nil)"},
+		{`(char< #\a (quote b))`, "#[error: char<: b is not a character]"},
 
 		{"(equal (car (quote (a b))) (quote a))", "t"},
 		{"(equal (cdr (quote (a b))) (quote a))", "nil"},
@@ -45,6 +148,23 @@ func TestEval(t *testing.T) {
 		{"(quote)", "#[error: ill-formed special form: (quote)]"},
 		{"(quote a b)", "#[error: ill-formed special form: (quote a b)]"},
 
+		{"`a", "a"},
+		{"`(a b)", "(a b)"},
+		{"`(a ,(car (quote (b c))))", "(a b)"},
+		{"`(a ,@(quote (b c)) d)", "(a b c d)"},
+		{"`(a ,@(quote (b c)))", "(a b c)"},
+		{"`(a ,@nil b)", "(a b)"},
+		{"`(a . ,(car (quote (b))))", "(a . b)"},
+		{"`(a ,@(quote (b . c)))", "(a b . c)"},
+		{"`(a ,@(quote (b c)) . d)", "(a b c . d)"},
+		{"`(a `(b ,(c ,(car (quote (x))))))", "(a `(b ,(c x)))"},
+		{"`(a `(b ,@(c ,@(quote (x y)))))", "(a `(b ,@(c x y)))"},
+		{"`,(car (quote (a)))", "a"},
+		{"`,@a", "#[error: ,@ is not within a list: ,@a]"},
+		{"`(a . ,@b)", "#[error: ,@ is not within a list: ,@b]"},
+		{"`(a ,@(quote b) c)", "#[error: The object b is not a list]"},
+		{"(quasiquote)", "#[error: ill-formed special form: (quasiquote)]"},
+
 		{"(car (quote (1 2)))", "1"},
 		{"(car (quote 1))", "#[error: car: 1 is not a pair]"},
 
@@ -58,37 +178,171 @@ func TestEval(t *testing.T) {
 		{"(cadar (quote ((a a') b c)))", "a'"},
 		{"(caddar (quote ((a a' a'') b c)))", "a''"},
 
-		{"(cons 1 2)", "(1 . 2)"},
-		{"(cons 1 (cons 2 ()))", "(1 2)"},
+		{"(cons (quote 1) (quote 2))", "(1 . 2)"},
+		{"(cons (quote 1) (cons (quote 2) ()))", "(1 2)"},
 		{"(cons (quote a) (quote (b c)))", "(a b c)"},
 
 		{"(list)", "nil"},
-		{"(list 1)", "(1)"},
-		{"(list 1 2)", "(1 2)"},
-		{"(list 1 2 3)", "(1 2 3)"},
+		{"(list (quote 1))", "(1)"},
+		{"(list (quote 1) (quote 2))", "(1 2)"},
+		{"(list (quote 1) (quote 2) (quote 3))", "(1 2 3)"},
 
-		{"((1 2) 3 4)", "#[error: invoke: 1 is not a function]"},
+		{"((quote 1) (quote 2))", "#[error: invoke: 1 is not a function]"},
 		{"((car (list cdr car)) (quote (1 2 3)))", "(2 3)"},
 
 		{"(cond ((atom (quote a)) (quote b)) ((quote t) (quote c)))", "b"},
 		{"(cond ((atom car) (quote b)) ((quote t) (quote c)))", "c"},
 		{"(cond ((atom car) (quote b)) (t (quote c)))", "c"},
 
+		// Any value other than nil is true.
+		{"(cond ((quote a) (quote b)))", "b"},
+		{"(cond (nil (quote b)))", "nil"},
+		{"(cond ((quote ()) (quote b)) ((cons nil nil) (quote c)))", "c"},
+
+		{"(if t (quote a) (quote b))", "a"},
+		{"(if nil (quote a) (quote b))", "b"},
+		{"(if (quote x) (quote a))", "a"},
+		{"(if nil (quote a))", "nil"},
+		{"(if)", "#[error: ill-formed special form: (if)]"},
+		{"(if t)", "#[error: ill-formed special form: (if t)]"},
+		{"(if t a b c)", "#[error: ill-formed special form: (if t a b c)]"},
+
+		{"(and)", "t"},
+		{"(and (quote a) (quote b))", "b"},
+		{"(and nil unbound-variable)", "nil"},
+		{"(and (quote a) nil (quote b))", "nil"},
+		{"(and t . a)", "#[error: ill-formed special form: (and t . a)]"},
+
+		{"(or)", "nil"},
+		{"(or (quote a) unbound-variable)", "a"},
+		{"(or nil (quote b))", "b"},
+		{"(or nil nil)", "nil"},
+		{"(or nil . a)", "#[error: ill-formed special form: (or nil . a)]"},
+
+		{"(when t (quote a) (quote b))", "b"},
+		{"(when nil unbound-variable)", "nil"},
+		{"(when t)", "nil"},
+		{"(unless nil (quote a) (quote b))", "b"},
+		{"(unless t unbound-variable)", "nil"},
+		{"(when)", "#[error: ill-formed special form: (when)]"},
+
+		{"(progn)", "nil"},
+		{"(progn (quote a) (quote b))", "b"},
+		{"(let ((x nil)) (progn (setq x (quote a)) (quote b)) x)", "a"},
+
 		{"((lambda () (quote a)))", "a"},
-		{"((lambda (a) a) b)", "b"},
+		{"((lambda (a) a) (quote b))", "b"},
 		{"((lambda (x y) (cons (car x) y)) (quote (a b)) (cdr (quote (c d))))",
 			"(a d)"},
 
 		// Ensure inner Lambda access extended environment.
-		{"(((lambda (x) (lambda (y) (cons x y))) a) b)", "(a . b)"},
+		{"(((lambda (x) (lambda (y) (cons x y))) (quote a)) (quote b))", "(a . b)"},
 		// Ensure body evaluation is equivalent to `progn`.
-		{"((lambda (x) a x c) c)", "c"},
+		{"((lambda (x) (quote a) x (quote c)) (quote c))", "c"},
 
 		{"(lambda)", "#[error: ill-formed special form: (lambda)]"},
 		{"(lambda ())", "#[error: ill-formed special form: (lambda nil)]"},
-		{"((lambda (x) x) 1 2)",
-			// FIXME: Error should print function reference.
-			"#[error: called with 2 arguments; requires exactly 1 argument]"},
+		{"((lambda (x) x) (quote 1) (quote 2))",
+			"#[error: lambda: called with 2 arguments; requires exactly 1 argument]"},
+
+		// Local bindings
+		{"(let () (quote a))", "a"},
+		{"(let ((x (quote a)) (y (quote b))) (cons x y))", "(a . b)"},
+		{"(let (x (y)) (list x y))", "(nil nil)"},
+		{"(let ((x (quote a))) (let ((x (quote b)) (y x)) (cons x y)))", "(b . a)"},
+		{"(let* ((x (quote a)) (y (cons x x))) y)", "(a . a)"},
+		{"(let ((x (quote a))) (let* ((x (quote b)) (y x)) (cons x y)))", "(b . b)"},
+		{`(letrec ((even (lambda (l) (cond ((null l) t) (t (odd (cdr l))))))
+                           (odd (lambda (l) (cond ((null l) nil) (t (even (cdr l)))))))
+                   (list (even (quote (a b))) (odd (quote (a b))) (even (quote (a)))))`,
+			"(t nil nil)"},
+		{"(let ((x (quote a))) (quote b) x)", "a"},
+		{"(let)", "#[error: ill-formed special form: (let)]"},
+		{"(let ())", "#[error: ill-formed special form: (let nil)]"},
+		{"(let x x)", "#[error: ill-formed special form: (let x x)]"},
+		{"(let* ((x a b)) x)", "#[error: ill-formed special form: (let* ((x a b)) x)]"},
+		{"(letrec (((x) a)) x)", "#[error: ill-formed special form: (letrec (((x) a)) x)]"},
+
+		// Assignment
+		{"(let ((x (quote a)) (y (quote b))) (setq x (quote c) y x) (list x y))", "(c c)"},
+		{"(let ((x (quote a))) (list (setq x (quote b)) x))", "(b b)"},
+		{"(let ((x (quote a))) (list (set! x (quote b)) x))", "(b b)"},
+		{"(setq)", "nil"},
+		{"(let ((x (quote a))) ((lambda () (setq x (quote b)))) x)", "b"},
+		{"(setq unbound-variable (quote a))", "#[error: unbound variable: unbound-variable]"},
+		{"(set! unbound-variable (quote a))", "#[error: unbound variable: unbound-variable]"},
+		{"(setq t nil)", "#[error: cannot assign to constant: t]"},
+		{"(setq x)", "#[error: ill-formed special form: (setq x)]"},
+		{"(setq (x) y)", "#[error: ill-formed special form: (setq (x) y)]"},
+		{"(set! x)", "#[error: ill-formed special form: (set! x)]"},
+		{"(set! x y z)", "#[error: ill-formed special form: (set! x y z)]"},
+		{`(defun make-counter ()
+                    (let ((n nil))
+                      (list (lambda () (setq n (cons t n)))
+                            (lambda () n))))
+                  (let* ((c1 (make-counter))
+                         (c2 (make-counter)))
+                    ((car c1))
+                    ((car c1))
+                    ((car c2))
+                    (list ((cadr c1)) ((cadr c2))))`,
+			"make-counter\n((t t) (t))"},
+
+		// Lambda lists
+		{"((lambda (a &optional b) (list a b)) (quote x))", "(x nil)"},
+		{"((lambda (a &optional (b (quote y))) (list a b)) (quote x))", "(x y)"},
+		{"((lambda (a &optional (b (quote y))) (list a b)) (quote x) (quote z))", "(x z)"},
+		{"((lambda (a &optional (b a)) (list a b)) (quote x))", "(x x)"},
+		{"((lambda (a &rest r) (list a r)) (quote x) (quote y) (quote z))", "(x (y z))"},
+		{"((lambda (a &rest r) (list a r)) (quote x))", "(x nil)"},
+		{"((lambda args args) (quote x) (quote y))", "(x y)"},
+		{"((lambda args args))", "nil"},
+		{"((lambda (a . r) (list a r)) (quote x) (quote y))", "(x (y))"},
+		{"((lambda (a &optional b &rest r) (list a b r)) (quote x) (quote y) (quote z) (quote w))",
+			"(x y (z w))"},
+		{"((lambda (a &optional b c) a))",
+			"#[error: lambda: called with 0 arguments; requires between 1 and 3 arguments]"},
+		{"((lambda (a &optional b c) a) t t t t)",
+			"#[error: lambda: called with 4 arguments; requires between 1 and 3 arguments]"},
+		{"((lambda (a b &rest c) a) t)",
+			"#[error: lambda: called with 1 argument; requires at least 2 arguments]"},
+		{"(lambda (&optional &rest) nil)", "#[error: ill-formed lambda list: (&optional &rest)]"},
+		{"(lambda (&rest) nil)", "#[error: ill-formed lambda list: (&rest)]"},
+		{"(lambda (&rest a b) nil)", "#[error: ill-formed lambda list: (&rest a b)]"},
+		{"(lambda (&rest a &optional b) nil)", "#[error: ill-formed lambda list: (&rest a &optional b)]"},
+		{"(lambda (a (b c)) nil)", "#[error: The object (b c) is not a symbol]"},
+		{"(lambda (&optional (b c d e)) nil)", "#[error: ill-formed lambda list: (&optional (b c d e))]"},
+		{"((lambda (&optional (a nil a-p)) (list a a-p)))", "(nil nil)"},
+		{"((lambda (&optional (a nil a-p)) (list a a-p)) nil)", "(nil t)"},
+
+		// Keyword arguments
+		{":foo", ":foo"},
+		{"(setq :foo t)", "#[error: cannot assign to constant: :foo]"},
+		{"((lambda (&key a b) (list a b)))", "(nil nil)"},
+		{"((lambda (&key a b) (list a b)) :b (quote x))", "(nil x)"},
+		{"((lambda (&key a b) (list a b)) :b (quote x) :a (quote y))", "(y x)"},
+		{"((lambda (&key a) a) :a (quote x) :a (quote y))", "x"},
+		{"((lambda (&key (a (quote x))) a))", "x"},
+		{"((lambda (&key (a nil a-p)) (list a a-p)))", "(nil nil)"},
+		{"((lambda (&key (a nil a-p)) (list a a-p)) :a nil)", "(nil t)"},
+		{"((lambda (&key ((:alpha a) (quote x))) a) :alpha (quote y))", "y"},
+		{"((lambda (&key ((alpha a))) a) (quote alpha) (quote y))", "y"},
+		{"((lambda (x &optional y &key z) (list x y z)) (quote a) (quote b) :z (quote c))", "(a b c)"},
+		{"((lambda (&rest r &key a) (list r a)) :a (quote x))", "((:a x) x)"},
+		{"((lambda (&key a &allow-other-keys) a) :b (quote x) :a (quote y))", "y"},
+		{"((lambda (&key a) a) :b (quote x) :allow-other-keys t)", "nil"},
+		{"((lambda (&key a) a) :b (quote x))", "#[error: unknown keyword argument: :b]"},
+		{"((lambda (&key a) a) :b (quote x) :allow-other-keys nil)", "#[error: unknown keyword argument: :b]"},
+		{"((lambda (&key a) a) :a)", "#[error: odd number of keyword arguments: (:a)]"},
+		{"((lambda (&key a) a) (quote x) (quote y))", "#[error: unknown keyword argument: x]"},
+		{"(lambda (&key a &optional b) nil)", "#[error: ill-formed lambda list: (&key a &optional b)]"},
+		{"(lambda (&allow-other-keys) nil)", "#[error: ill-formed lambda list: (&allow-other-keys)]"},
+		{"(lambda (&key &allow-other-keys a) nil)", "#[error: ill-formed lambda list: (&key &allow-other-keys a)]"},
+		{"(lambda (&key ((a)) b) nil)", "#[error: ill-formed lambda list: (&key ((a)) b)]"},
+
+		{`(defmacro my-when (test &body body) ` + "`" + `(cond (,test ,@body)))
+                  (my-when t (quote a) (quote b))`,
+			"my-when\nb"},
 
 		{`((label ff (lambda (x)
                                (cond ((atom x) x)
@@ -97,65 +351,615 @@ func TestEval(t *testing.T) {
 			"a"},
 
 		// Permanent function definitions
-		{`(defun ff () 1) (ff)`, "ff\n1"},
+		{`(defun ff () (quote 1)) (ff)`, "ff\n1"},
 		{`(defun ff (x)
                     (cond ((atom x) x)
                           ((quote t) (ff (car x)))))
                   (ff (quote ((a b) c)))`,
 			"ff\na"},
+		{`(let ((x 1)) (defun inner () x) (inner)) (inner)`, "1\n1"},
+
+		// Macros defined after the functions using them
+		{`(defun late-user (x) (let ((y 2)) (list (late-when x (list x y)) y)))
+                  (defmacro late-when (c x) (list (quote if) c x))
+                  (list (late-user 1) (late-user nil))`,
+			"late-user\nlate-when\n(((1 2) 2) (nil 2))"},
+		{`(defun late-tail (x) (late-when x (car x))) (late-tail (quote (a)))`, "late-tail\na"},
+		{`(defmacro late-when (c x) (list (quote if) x c)) (late-user 1)`, "late-when\n(1 2)"},
+
+		// Function introspection
+		{`(defun ff (x &optional (y x)) (list x y)) ff`, "ff\n#[compound-function ff (x &optional (y x))]"},
+		{"(lambda (x) x)", "#[compound-function lambda (x)]"},
+		{"(label ff (lambda () nil))", "#[compound-function ff nil]"},
+		{"car", "#[compiled-function car]"},
+		{"(list (function-name car) (function-name ff) (function-name (lambda () nil)))", "(car ff nil)"},
+		{"(list (function-lambda-list ff) (function-lambda-list (lambda args args)))", "((x &optional (y x)) args)"},
+		{"(list (function-lambda-list cons) (function-lambda-list list) (function-lambda-list error))",
+			"((arg1 arg2) (&rest args) (&rest args))"},
+		{"(function-name (call/cc (lambda (k) k)))", "nil"},
+		{"(function-name (quote car))", "#[error: function-name: car is not a function]"},
+		{`(defun documented (x) "Return x." x) (list (documented 1) (function-documentation documented))`,
+			"documented\n(1 \"Return x.\")"},
+		{`(defmacro documented-macro () "Expand to nil." nil) (documented-macro)`, "documented-macro\nnil"},
+		{`(list ((lambda () "value")) (function-documentation (lambda () "value")))`, "(\"value\" nil)"},
+		{`(function-documentation (label ff (lambda (x) "Return x." x)))`, "\"Return x.\""},
+		{"(list (function-documentation car) (function-documentation ff))", "(nil nil)"},
+		{"(function-documentation (quote car))", "#[error: function-documentation: car is not a function]"},
+		{"(ff)", "#[error: ff: called with 0 arguments; requires between 1 and 2 arguments]"},
+		{"(car)", "#[error: car: called with 0 arguments; requires exactly 1 argument]"},
+		{"(cons (quote a))", "#[error: cons: called with 1 arguments; requires exactly 2 arguments]"},
+
+		// Macros
+		{`(defmacro my-if (c a b) (list (quote cond) (list c a) (list t b)))
+                  (my-if (atom (quote x)) (quote a) (quote b))
+                  (my-if (atom (list (quote x))) (quote a) (quote b))`,
+			"my-if\na\nb"},
+		{`(defmacro m () (quote (quote x))) m (m)`, "m\n#[macro m]\nx"},
+		{`(defmacro my-car (x) (list (quote car) x))
+                  (defmacro my-caar (x) (list (quote my-car) (list (quote my-car) x)))
+                  (macroexpand-1 (quote (my-caar y)))
+                  (macroexpand (quote (my-caar y)))
+                  (my-caar (quote ((a) b)))`,
+			"my-car\nmy-caar\n(my-car (my-car y))\n(car (my-car y))\na"},
+		{`(macroexpand (quote (car y)))`, "(car y)"},
+		{`(macroexpand (quote y))`, "y"},
+		{`(+ 1 (car (car (disassemble (quote (quote (x)))))))`, "1"},
+		{`(disassemble (quote (cons (quote a) x)))`,
+			"((0 global-function cons 3) (1 const a) (2 global x) (3 tail-call 2) (4 return))"},
+		{`(disassemble (quote unbound-function))`, "#[error: disassemble: unbound-function is not a compiled function]"},
+		{"(defmacro)", "#[error: ill-formed special form: (defmacro)]"},
+		{"(defmacro m)", "#[error: ill-formed special form: (defmacro m)]"},
+		{`(defmacro shadowed (x) (list (quote quote) x))
+                  (let ((shadowed list)) (shadowed 1 2))
+                  (let* ((shadowed list) (y (shadowed 1 2))) y)
+                  ((lambda (shadowed) (shadowed 1 2)) list)
+                  ((lambda (shadowed &optional (y (shadowed 1 2))) y) list)
+                  ((label shadowed (lambda (n) (if (zerop n) (quote done) (shadowed (- n 1))))) 2)
+                  (shadowed (a b))`,
+			"shadowed\n(1 2)\n(1 2)\n(1 2)\n(1 2)\ndone\n(a b)"},
 
 		// Function application
-		{`(apply)`, "#[error: called with 0 arguments; requires at least 1 argument]"},
+		{`(apply)`, "#[error: apply: called with 0 arguments; requires at least 1 argument]"},
 		{`(apply list)`, "nil"},
 		{`(apply list (quote (a)))`, "(a)"},
 		{`(apply list (quote a) (list (quote b)))`, "(a b)"},
 
 		// Errors
-		{`(error something went wrong)`, "#[error: something went wrong]"},
+		{`(error (quote something) (quote went) (quote wrong))`, "#[error: something went wrong]"},
 
 		// Error recovery
-		{`(cons a (ignore-errors (lambda () (error trapped))))`, "(a . #[error: trapped])"},
-		{`((lambda (x) (ignore-errors (lambda () x))) 3)`, "3"},
+		{`(cons (quote a) (ignore-errors (lambda () (error (quote trapped)))))`, "(a . #[error: trapped])"},
+		{`((lambda (x) (ignore-errors (lambda () x))) (quote 3))`, "3"},
+		{"unbound-variable", "#[error: unbound variable: unbound-variable]"},
+
+		// Escaping continuations
+		{`(call/cc (lambda (k) (cons (quote a) (k (quote b)))))`, "b"},
+		{`(cons (quote a) (call-with-current-continuation (lambda (k) (quote b))))`, "(a . b)"},
+		{`(ignore-errors (lambda () (call/cc (lambda (k) (k (quote a))))))`, "a"},
+		{`(call/cc (lambda (k) (ignore-errors (lambda () (k (quote out)))) (quote not-reached)))`, "out"},
+		{`(apply call/cc (list (lambda (k) (k (quote applied)))))`, "applied"},
+		{`(defun find-first (pred l)
+                    (call/cc (lambda (return)
+                               ((label walk (lambda (l)
+                                              (cond ((null l) nil)
+                                                    ((pred (car l)) (return (car l)))
+                                                    (t (walk (cdr l))))))
+                                l))))
+                  (find-first atom (quote ((a) (b) c (d))))`,
+			"find-first\nc"},
+		{`(call/cc)`, "#[error: call-with-current-continuation: called with 0 arguments; requires exactly 1 argument]"},
+		{`(call/cc (lambda (k) (k)))`, "#[error: called with 0 arguments; requires exactly 1 argument]"},
+
+		// Cleanup forms
+		{`(defun trace () nil)
+                  (defun note (x) (setq trace (cons x trace)))
+                  (progn (setq trace nil) (list (unwind-protect (quote a) (note (quote b)) (note (quote c))) trace))`,
+			"trace\nnote\n(a (c b))"},
+		{`(progn (setq trace nil)
+                         (list (ignore-errors (lambda () (unwind-protect (error (quote boom)) (note (quote cleanup)))))
+                               trace))`,
+			"(#[error: boom] (cleanup))"},
+		{`(progn (setq trace nil)
+                         (list (ignore-errors (lambda ()
+                                                (unwind-protect
+                                                  (unwind-protect (error (quote boom)) (note (quote inner)))
+                                                  (note (quote outer)))))
+                               trace))`,
+			"(#[error: boom] (outer inner))"},
+		{`(progn (setq trace nil)
+                         (list (call/cc (lambda (k) (unwind-protect (k (quote escaped)) (note (quote cleanup)))))
+                               trace))`,
+			"(escaped (cleanup))"},
+		{`(progn (setq trace nil) (unwind-protect (error (quote boom)) (note (quote cleanup))))
+                  trace`,
+			"#[error: boom]\n(cleanup)"},
+		{`(progn (setq trace nil)
+                         (list (dynamic-wind (lambda () (note (quote before)))
+                                             (lambda () (note (quote during)) (quote v))
+                                             (lambda () (note (quote after))))
+                               trace))`,
+			"(v (after during before))"},
+		{`(progn (setq trace nil)
+                         (list (ignore-errors (lambda ()
+                                                (dynamic-wind (lambda () (note (quote before)))
+                                                              (lambda () (error (quote boom)))
+                                                              (lambda () (note (quote after))))))
+                               trace))`,
+			"(#[error: boom] (after before))"},
+		{`(unwind-protect (quote a))`, "a"},
+		{`(unwind-protect)`, "#[error: ill-formed special form: (unwind-protect)]"},
+		{`(dynamic-wind (lambda () nil) (lambda () nil))`, "#[error: dynamic-wind: called with 2 arguments; requires exactly 3 arguments]"},
+		{`(handler-case (car (quote a)) (error (c) c))`, "#[error: car: a is not a pair]"},
+		{`(handler-case (car (quote a)) (not-a-function () (quote function)) (not-a-pair () (quote pair)))`, "pair"},
+		{`(handler-case ((quote a)) (not-a-function () (quote function)) (type-error () (quote type)))`, "function"},
+		{`(handler-case (car) (arity-error () (quote arity)) (error () (quote error)))`, "arity"},
+		{`(handler-case unbound (unbound-variable (c) (condition-type c)))`, "unbound-variable"},
+		{`(handler-case (error (quote boom)) (program-error () (quote program)) (simple-error () (quote simple)))`, "simple"},
+		{`(handler-case (quote a) (error () (quote b)))`, "a"},
+		{`(handler-case (car (quote a)) (not-a-function () (quote function)))`, "#[error: car: a is not a pair]"},
+		{`(handler-case (car (quote a)) (error ()))`, "nil"},
+		{`(handler-case (handler-bind ((error (lambda (c) (quote declined)))) (error (quote boom)))
+                               (error (c) (list (quote outer) c)))`,
+			"(outer #[error: boom])"},
+		{`(handler-bind ((error (lambda (c) (invoke-restart (quote use-value) (quote fixed)))))
+                   (restart-case (car (quote a)) (use-value (x) (list x x))))`,
+			"(fixed fixed)"},
+		{`(restart-case (invoke-restart (quote retry)) (retry () (quote retried)))`, "retried"},
+		{`(restart-case (list (invoke-restart (quote retry))) (abort () (quote aborted)) (retry () (quote retried)))`, "retried"},
+		{`(restart-case (compute-restarts) (a ()) (b ()))`, "(#[restart a] #[restart b])"},
+		{`(restart-case (restart-case (find-restart (quote a)) (b ())) (a ()))`, "#[restart a]"},
+		{`(find-restart (quote a))`, "nil"},
+		{`(invoke-restart (quote a))`, "#[error: no restart named a is active]"},
+		{`(handler-case (invoke-restart (quote a)) (control-error (c) (condition-type c)))`, "control-error"},
+		{`(signal (make-condition (quote warning) (quote careful)))`, "nil"},
+		{`(handler-case (signal (make-condition (quote warning) (quote careful))) (warning (c) c))`, "#[warning: careful]"},
+		{`(condition-type (make-condition (quote type-error) (quote oops)))`, "type-error"},
+		{`(make-condition (quote warning) "watch out:" "x")`, "#[warning: watch out: x]"},
+		{`(handler-bind ((error (lambda (c) (invoke-restart (quote use-value) (quote outer)))))
+                   (restart-case (ignore-errors (lambda () (error (quote boom)))) (use-value (x) x)))`,
+			"#[error: boom]"},
+		{`(handler-case (error (make-condition (quote program-error) (quote oops))) (program-error (c) (list (condition-type c) c)))`,
+			"(program-error #[error: oops])"},
+		{`(catch (quote a) (quote b))`, "b"},
+		{`(catch (quote a))`, "nil"},
+		{`(catch (quote a) (cons (quote b) (throw (quote a) (quote c))))`, "c"},
+		{`(catch (quote a) (catch (quote b) (throw (quote a) (quote c))) (quote d))`, "c"},
+		{`(catch (quote a) (throw (quote a) (catch (quote a) (throw (quote a) (quote b)))))`, "b"},
+		{`(defun search (x tree)
+                    (cond ((eq x tree) (throw (quote found) tree))
+                          ((atom tree) nil)
+                          (t (search x (car tree)) (search x (cdr tree)))))
+                  (catch (quote found) (search (quote c) (quote (a (b (c d))))))`,
+			"search\nc"},
+		{`(catch (quote a) (ignore-errors (lambda () (throw (quote a) (quote b)))))`, "b"},
+		{`(catch (quote a) (handler-case (throw (quote a) (quote b)) (error (c) c)))`, "b"},
+		{`(progn (setq trace nil)
+                         (list (catch (quote a) (unwind-protect (throw (quote a) (quote b)) (note (quote cleanup))))
+                               trace))`,
+			"(b (cleanup))"},
+		{`(throw (quote a) (quote b))`, "#[error: throw: no catch for tag a]"},
+		{`(catch (quote a) (handler-case (throw (quote b) (quote c)) (control-error (c) (condition-type c))))`, "control-error"},
+		{`(catch (list (quote a)) (throw (list (quote a)) (quote b)))`, "b"},
+		{`(catch 100000000000000000000 (throw (* 10000000000 10000000000) (quote b)))`, "b"},
+		{`(catch "tag" (throw (string-append "ta" "g") (quote b)))`, "b"},
+		{`(catch (quote a) (catch 1 (throw 1.0 (quote b))) (quote c))`, "#[error: throw: no catch for tag 1.0]"},
+		{`(catch)`, "#[error: ill-formed special form: (catch)]"},
+		{`(values (quote a) (quote b))`, "a\nb"},
+		{`(values (quote a))`, "a"},
+		{`(values)`, "; no values"},
+		{`(values) (quote a)`, "; no values\na"},
+		{`(list (values (quote a) (quote b)) (values))`, "(a nil)"},
+		{`(multiple-value-list (values (quote a) (quote b)))`, "(a b)"},
+		{`(multiple-value-list (values))`, "nil"},
+		{`(multiple-value-list (quote a))`, "(a)"},
+		{`(multiple-value-bind (x y z) (values (quote a) (quote b)) (list x y z))`, "(a b nil)"},
+		{`(multiple-value-bind (x) (values (quote a) (quote b)) x)`, "a"},
+		{`(multiple-value-bind (x y) (values (quote a) (quote b)))`, "nil"},
+		{`(multiple-value-call list (values (quote a) (quote b)) (quote c) (values) (values (quote d) (quote e)))`, "(a b c d e)"},
+		{`(multiple-value-call list)`, "nil"},
+		{`(defun divide (x y) (values x y))
+                  (multiple-value-list (divide (quote a) (quote b)))
+                  (multiple-value-list (progn (divide (quote a) (quote b))))
+                  (multiple-value-list (if t (divide (quote a) (quote b))))
+                  (multiple-value-list (let ((x (divide (quote a) (quote b)))) x))
+                  (multiple-value-list (apply divide (list (quote a) (quote b))))`,
+			"divide\n(a b)\n(a b)\n(a b)\n(a)\n(a b)"},
+		{`(defun divide-later (x) (if x (divide-later nil) (values (quote a) (quote b))))
+                  (multiple-value-list (divide-later t))`,
+			"divide-later\n(a b)"},
+		{`(if (values nil t) (quote yes) (quote no))`, "no"},
+		{`(multiple-value-list (or (values nil (quote a)) (values (quote b) (quote c))))`, "(b c)"},
+		{`(multiple-value-list (or (values (quote a) (quote b)) (quote c)))`, "(a)"},
+		{`(multiple-value-list (and (values (quote a) (quote b)) (values (quote c) (quote d))))`, "(c d)"},
+		{"`(,(values (quote a) (quote b)) ,@(values (quote (c)) (quote d)))", "(a c)"},
+		{`(progn (setq trace (values (quote a) (quote b))) trace)`, "a"},
+		{`(multiple-value-list (handler-case (values (quote a) (quote b)) (error () nil)))`, "(a b)"},
+		{`(multiple-value-list (catch (quote x) (values (quote a) (quote b))))`, "(a b)"},
+		{`(multiple-value-list (ignore-errors (lambda () (values (quote a) (quote b)))))`, "(a b)"},
+		{`(multiple-value-bind a (values))`, "#[error: ill-formed special form: (multiple-value-bind a (values))]"},
+		{`(multiple-value-list)`, "#[error: ill-formed special form: (multiple-value-list)]"},
+		{`(multiple-value-call)`, "#[error: ill-formed special form: (multiple-value-call)]"},
+		{`(defparameter *level* (quote outer))
+                  (defun level () *level*)
+                  (list (level) (let ((*level* (quote inner))) (level)) (level))`,
+			"*level*\nlevel\n(outer inner outer)"},
+		{`(let* ((x (quote a)) (*level* x)) (list x (level)))`, "(a a)"},
+		{`(letrec ((*level* (quote a)) (f (lambda () (level)))) (f))`, "a"},
+		{`(defun level-of (*level*) (level))
+                  (list (level-of (quote param)) (level))`,
+			"level-of\n(param outer)"},
+		{`(defun default-level (&optional (*level* (quote default)) (x (level))) x)
+                  (list (default-level) (level))`,
+			"default-level\n(default outer)"},
+		{`(list (let ((*level* (quote inner))) (setq *level* (quote changed)) (level)) (level))`, "(changed outer)"},
+		{`(list (ignore-errors (lambda () (let ((*level* (quote inner))) (error (level))))) (level))`,
+			"(#[error: inner] outer)"},
+		{`(list (catch (quote x) (let ((*level* (quote inner))) (throw (quote x) (level)))) (level))`, "(inner outer)"},
+		{`(list (call/cc (lambda (k) (let ((*level* (quote inner))) (k (level))))) (level))`, "(inner outer)"},
+		{`(defun level-until (x) (let ((*level* x)) (if (eq x (quote stop)) (level) (level-until (quote stop)))))
+                  (list (level-until (quote go)) (level))`,
+			"level-until\n(stop outer)"},
+		{`(defun shadowed-level () *shadowed*)
+                  (let ((*shadowed* (quote lexical)))
+                    (defvar *shadowed* (quote global))
+                    (list *shadowed* (let ((*shadowed* (quote dynamic))) (shadowed-level)) (shadowed-level)))`,
+			"shadowed-level\n(lexical dynamic global)"},
+		{`(defvar *level* (quote again))
+                  (level)`,
+			"*level*\nouter"},
+		{`(defparameter *level* (quote again) (quote documentation))
+                  (level)`,
+			"*level*\nagain"},
+		{`(defvar *unset-level*)
+                  *unset-level*`,
+			"*unset-level*\nnil"},
+		{`(defvar)`, "#[error: ill-formed special form: (defvar)]"},
+		{`(defparameter x)`, "#[error: ill-formed special form: (defparameter x)]"},
+		{`(defvar t)`, "#[error: ill-formed special form: (defvar t)]"},
+		{`(defun bt-inner (x) (car x))
+                  (defun bt-outer (x) (list (bt-inner x)))
+                  (bt-outer (quote a))
+                  backtrace`,
+			"bt-inner\nbt-outer\n#[error: car: a is not a pair]\n  0: (bt-inner a) from (bt-inner x)\n  1: (bt-outer a) from (bt-outer (quote a))"},
+		{`(defun bt-tail (x) (bt-inner x))
+                  (list (bt-tail (quote b)))
+                  backtrace`,
+			"bt-tail\n#[error: car: b is not a pair]\n  0: (bt-inner b) from (bt-inner x)"},
+		{`(list ((lambda (x) (list (bt-inner x))) (quote c)))
+                  (quote ok)
+                  backtrace`,
+			"#[error: car: c is not a pair]\nok\n  0: (bt-inner c) from (bt-inner x)\n  1: (lambda c) from ((lambda (x) (list (bt-inner x))) (quote c))"},
+		{`(defun bt-late (x) (list (bt-late-macro x)))
+                  (defmacro bt-late-macro (x) (list (quote bt-inner) x))
+                  (bt-late (quote d))
+                  backtrace`,
+			"bt-late\nbt-late-macro\n#[error: car: d is not a pair]\n  0: (bt-inner d) from (bt-inner x)\n  1: (bt-late d) from (bt-late (quote d))"},
+		{`(handler-case)`, "#[error: ill-formed special form: (handler-case)]"},
+		{`(handler-case a (error))`, "#[error: ill-formed special form: (handler-case a (error))]"},
+		{`(handler-bind (error) a)`, "#[error: ill-formed special form: (handler-bind (error) a)]"},
+		{`(restart-case (error (quote boom)) (use-value (x) x))
+                  (invoke-restart (quote use-value) (quote fixed))`,
+			"#[error: boom]\nRestarts:\n  0: use-value\n  1: abort\nfixed"},
+		{`(restart-case (error (quote boom)) (abort ()) (retry () (quote retried)))
+                  1`,
+			"#[error: boom]\nRestarts:\n  0: abort\n  1: retry\n  2: abort\nretried"},
+		{`(restart-case (error (quote boom)) (retry () (quote retried)))
+                  7 0`,
+			"#[error: boom]\nRestarts:\n  0: retry\n  1: abort\n7\nretried"},
+		{`(restart-case (error (quote boom)) (retry () (quote retried)))
+                  retry`,
+			"#[error: boom]\nRestarts:\n  0: retry\n  1: abort\nretried"},
+		{`(restart-case (error (quote boom)) (retry () (quote retried)))
+                  abort (quote a)`,
+			"#[error: boom]\nRestarts:\n  0: retry\n  1: abort\n#[error: boom]\na"},
+		{`(restart-case (error (quote boom)) (use-value (x) (list x x)))
+                  0 (quote fixed)`,
+			"#[error: boom]\nRestarts:\n  0: use-value\n  1: abort\n(fixed fixed)"},
+		{`(restart-case (error (quote boom)) (store-value (x y &optional z) (list x y z)))
+                  store-value (car (quote a)) (car (quote (b))) 3`,
+			"#[error: boom]\nRestarts:\n  0: store-value\n  1: abort\n#[error: car: a is not a pair]\n(b 3 nil)"},
+		{`(restart-case (error (quote boom)) (use-value (x) x))
+                  use-value`,
+			"#[error: boom]\nRestarts:\n  0: use-value\n  1: abort\n#[error: boom]"},
+		{`(handler-case (if) (ill-formed-special-form () (quote handled)))`, "#[error: ill-formed special form: (if)]"},
 	}
-	for _, tc := range testCases {
-		t.Run(tc.expr, func(t *testing.T) {
-			// Ensure that test case provides a matcher.
-			var matcher Matcher
-			switch v := tc.want.(type) {
-			case string:
-				matcher = stringMatcher(v)
-			case Matcher:
-				matcher = v
-			default:
-				t.Fatalf("test requires a matcher, got %#v", tc.want)
-			}
+	forEachEvaluator(t, func(t *testing.T) {
+		defer Reset() // each evaluator begins with an empty environment
+		for _, tc := range testCases {
+			t.Run(tc.expr, func(t *testing.T) {
+				// Ensure that test case provides a matcher.
+				var matcher Matcher
+				switch v := tc.want.(type) {
+				case string:
+					matcher = stringMatcher(v)
+				case Matcher:
+					matcher = v
+				default:
+					t.Fatalf("test requires a matcher, got %#v", tc.want)
+				}
+
+				// Read, eval, and print until done.
+				r := strings.NewReader(tc.expr)
+				var buf bytes.Buffer
+				if err := run(r, &buf, ""); err != nil {
+					t.Fatal(err)
+				}
+
+				// Drop trailing newline to simplify test cases.
+				got := strings.TrimRight(buf.String(), "\n")
+				if !matcher.MatchString(got) {
+					t.Errorf("want %q, got %q", tc.want, got)
+				}
+			})
+		}
+	})
+}
 
-			// Read, eval, and print until done.
-			r := strings.NewReader(tc.expr)
+func TestContinuation(t *testing.T) {
+	defer Reset() // clean up environment post-test
+
+	// Re-entrant continuations are only supported by the machine.
+	defer func(old Evaluator) { DefaultEvaluator = old }(DefaultEvaluator)
+	DefaultEvaluator = Compiler
+
+	testCases := []struct {
+		name string
+		expr string
+		want string
+	}{
+		{"reentry", `(let ((k nil) (l nil))
+                               (setq l (cons (call/cc (lambda (c) (setq k c) (quote a))) l))
+                               (cond ((equal (car l) (quote a)) (k (quote b)))
+                                     ((equal (car l) (quote b)) (k (quote c)))
+                                     (t l)))`,
+			"(c b a)"},
+		{"toplevel", `(defun k () nil)
+                              (cons (quote a) (call/cc (lambda (c) (setq k c) (quote b))))
+                              (k (quote c))
+                              (k (quote d))`,
+			"k\n(a . b)\n(a . c)\n(a . d)"},
+		{"generator", `(defun make-generator (tree)
+                                 (letrec ((return nil)
+                                          (resume nil)
+                                          (walk (lambda (x)
+                                                  (cond ((null x) nil)
+                                                        ((atom x) (call/cc (lambda (k)
+                                                                             (setq resume k)
+                                                                             (return x))))
+                                                        (t (walk (car x)) (walk (cdr x)))))))
+                                   (lambda ()
+                                     (call/cc (lambda (r)
+                                                (setq return r)
+                                                (if resume
+                                                    (resume nil)
+                                                    (progn (walk tree) (return (quote done)))))))))
+                               (defun next () nil)
+                               (progn (setq next (make-generator (quote ((a b) (c (d)))))) nil)
+                               (list (next) (next) (next) (next) (next))`,
+			"make-generator\nnext\nnil\n(a b c d done)"},
+		{"ignore-errors", `(let ((k nil) (n nil))
+                                     (let ((r (ignore-errors (lambda ()
+                                                               (call/cc (lambda (c) (setq k c)))
+                                                               (if n (error (quote boom)) (quote first))))))
+                                       (if n r (progn (setq n t) (k nil)))))`,
+			"#[error: boom]"},
+		{"dynamic-wind", `(defun trace () nil)
+                                  (defun note (x) (setq trace (cons x trace)))
+                                  (progn (setq trace nil)
+                                         (let ((k nil) (n nil))
+                                           (dynamic-wind (lambda () (note (quote in)))
+                                                         (lambda () (call/cc (lambda (c) (setq k c))) (note (quote body)))
+                                                         (lambda () (note (quote out))))
+                                           (unless n (setq n t) (k nil)))
+                                         trace)`,
+			"trace\nnote\n(out body in out body in)"},
+		{"finished", `(defun k () nil)
+                              (ignore-errors (lambda () (call/cc (lambda (c) (setq k c) (quote a)))))
+                              (cons (quote b) (k (quote c)))`,
+			"k\na\nc"},
+		{"special", `(defvar *state* (quote top))
+                             (defun k () nil)
+                             (defun seen () nil)
+                             (progn (setq seen nil) (let ((*state* (quote bound))) (call/cc (lambda (c) (setq k c))) (setq seen (cons *state* seen))))
+                             (if (cdr seen) seen (k nil))
+                             *state*`,
+			"*state*\nk\nseen\n(bound)\n(bound bound)\ntop"},
+		{"nested", `(defun k () nil)
+                            (defmacro escape () (k (quote escaped)))
+                            (call/cc (lambda (c) (setq k c) (macroexpand (quote (escape)))))`,
+			"k\nescape\nescaped"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
 			var buf bytes.Buffer
-			if err := run(r, &buf, ""); err != nil {
+			if err := run(strings.NewReader(tc.expr), &buf, ""); err != nil {
 				t.Fatal(err)
 			}
 
-			// Drop trailing newline to simplify test cases.
 			got := strings.TrimRight(buf.String(), "\n")
-			if !matcher.MatchString(got) {
+			if got != tc.want {
 				t.Errorf("want %q, got %q", tc.want, got)
 			}
 		})
 	}
 }
 
-func TestEnv(t *testing.T) {
+func TestTailCall(t *testing.T) {
 	defer Reset() // clean up environment post-test
 
-	// Auto-quoted name of symbol; will be the result if not bound.
-	sym := value.Intern("foo")
+	// A long list is used to count iterations.
+	const n = 1000000
+	var counter value.Value = NIL
+	for i := 0; i < n; i++ {
+		counter = value.Cons(T, counter)
+	}
+	UserEnvironment.Define("counter", counter)
+
+	testCases := []struct {
+		name string
+		defs string
+		expr string
+	}{
+		{"cond", `(defun loop (l)
+                            (cond ((null l) (quote done))
+                                  (t (loop (cdr l)))))`,
+			"(loop counter)"},
+		{"body", `(defun loop (l)
+                            (quote ignored)
+                            (cond ((null l) (quote done))
+                                  (t (quote ignored) (loop (cdr l)))))`,
+			"(loop counter)"},
+		{"mutual", `(defun even (l)
+                              (cond ((null l) (quote done))
+                                    (t (odd (cdr l)))))
+                            (defun odd (l)
+                              (cond ((null l) (quote done))
+                                    (t (even (cdr l)))))`,
+			"(even counter)"},
+		{"lambda", `(defun loop (l)
+                              (cond ((null l) (quote done))
+                                    (t ((lambda (x) (loop x)) (cdr l)))))`,
+			"(loop counter)"},
+		{"if", `(defun loop (l)
+                          (if (null l) (quote done) (progn (quote ignored) (loop (cdr l)))))`,
+			"(loop counter)"},
+		{"and", `(defun loop (l)
+                           (or (and (null l) (quote done))
+                               (when t (loop (cdr l)))))`,
+			"(loop counter)"},
+		{"macro", `(defun late-if () nil)
+                             (defun loop (l)
+                               (late-if (null l) (quote done) (loop (cdr l))))
+                             (defmacro late-if (c x y) (list (quote if) c x y))`,
+			"(loop counter)"},
+		{"let", `(defun loop (l)
+                           (let ((next (cdr l)))
+                             (cond ((null next) (quote done))
+                                   (t (loop next)))))`,
+			"(loop counter)"},
+	}
+	forEachEvaluator(t, func(t *testing.T) {
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				var buf bytes.Buffer
+				if err := run(strings.NewReader(tc.defs), &buf, ""); err != nil {
+					t.Fatal(err)
+				}
+
+				if got := EvalString(tc.expr); got.String() != "done" {
+					t.Errorf("want done, got %s", got)
+				}
+			})
+		}
+	})
+}
+
+func TestResetSpecials(t *testing.T) {
+	forEachEvaluator(t, func(t *testing.T) {
+		defer Reset()
+
+		// After Reset, a variable that was special is bound
+		// lexically again.
+		EvalString("(defvar *former* 1)")
+		Reset()
+		for _, tc := range []struct {
+			expr string
+			want string
+		}{
+			{"(let ((*former* 2)) *former*)", "2"},
+			{"((lambda (*former*) *former*) 3)", "3"},
+			{"(progn (defun former () *former*) (let ((*former* 4)) (former)))", "#[error: unbound variable: *former*]"},
+		} {
+			if got := EvalString(tc.expr).String(); got != tc.want {
+				t.Errorf("%s: want %s, got %s", tc.expr, tc.want, got)
+			}
+		}
+	})
+}
 
-	// FIXME: Auto-quoting should be removed to simplify this
-	// test. We should expect NIL (or maybe an error) for unbound
-	// variables.
-	if v := EvalString("foo"); v != sym {
+func TestMaxSteps(t *testing.T) {
+	defer Reset() // clean up environment post-test
+	defer func() { MaxSteps = 0 }()
+
+	testCases := []struct {
+		expr string
+		want string
+	}{
+		{"(loop (quote a))", "#[budget-exhausted: budget exhausted after 100 steps]"},
+		{"(ignore-errors (lambda () (loop (quote a))))", "#[budget-exhausted: budget exhausted after 100 steps]"},
+		{"(handler-case (loop (quote a)) (condition (c) c))", "#[budget-exhausted: budget exhausted after 100 steps]"},
+		{"(restart-case (loop (quote a)) (use-value (x) x))", "#[budget-exhausted: budget exhausted after 100 steps]"},
+		{"(unwind-protect (loop (quote a)) (loop (quote b)))", "#[budget-exhausted: budget exhausted after 100 steps]"},
+		{"(unwind-protect (loop (quote a)) unbound-in-cleanup)", "#[budget-exhausted: budget exhausted after 100 steps]"},
+		{"(expand)", "#[budget-exhausted: budget exhausted after 100 steps]"},
+		{"(cdr (quote (a b)))", "(b)"},
+	}
+	forEachEvaluator(t, func(t *testing.T) {
+		MaxSteps = 0
+		for _, def := range []string{
+			"(defun loop (x) (loop x))",
+			"(defmacro expand () (quote (expand)))",
+		} {
+			EvalString(def)
+		}
+
+		MaxSteps = 100
+		for _, tc := range testCases {
+			t.Run(tc.expr, func(t *testing.T) {
+				got := EvalString(tc.expr)
+				if got.String() != tc.want {
+					t.Fatalf("want %s, got %s", tc.want, got)
+				}
+				if c, ok := got.(*value.Condition); ok && c.Type != value.BudgetExhausted {
+					t.Errorf("want %s, got %s", value.BudgetExhausted.Name, c.Type.Name)
+				}
+			})
+		}
+	})
+}
+
+func TestMaxDepth(t *testing.T) {
+	defer Reset() // clean up environment post-test
+
+	testCases := []struct {
+		expr string
+		want string
+	}{
+		{"(count 100)", "100"},
+		{"(count 1000000)", "#[error: stack overflow (depth 100001)]"},
+		{"(condition-type (ignore-errors (lambda () (count 1000000))))", "stack-overflow"},
+		{"(handler-case (count 1000000) (stack-overflow () (count 2)))", "2"},
+		{`(restart-case (handler-bind ((stack-overflow (lambda (c) (invoke-restart (quote use-value) (count 1)))))
+                                   (count 1000000))
+                   (use-value (x) x))`,
+			"1"},
+		{"(count 100)", "100"},
+		{"(expand)", "#[error: stack overflow (depth 100001)]"},
+		{"(list (expand))", "#[error: stack overflow (depth 100001)]"},
+		{"(expand-later)", "#[error: stack overflow (depth 100001)]"},
+		{"(count 100)", "100"},
+	}
+	forEachEvaluator(t, func(t *testing.T) {
+		EvalString("(defun count (n) (if (zerop n) 0 (+ 1 (count (- n 1)))))")
+		EvalString("(defmacro expand () (quote (expand)))")
+		EvalString("(defun expand-late () nil)")
+		EvalString("(defun expand-later () (expand-late))")
+		EvalString("(defmacro expand-late () (quote (expand-late)))")
+		for _, tc := range testCases {
+			t.Run(tc.expr, func(t *testing.T) {
+				if got := EvalString(tc.expr); got.String() != tc.want {
+					t.Errorf("want %s, got %s", tc.want, got)
+				}
+			})
+		}
+	})
+}
+
+func TestEnv(t *testing.T) {
+	defer Reset() // clean up environment post-test
+
+	unbound := &value.Condition{Type: value.UnboundVariable, Message: "unbound variable: foo"}
+	if v := EvalString("foo"); v.Equal(unbound) != T {
 		t.Fatalf("foo was already bound: %s", v)
 	}
 
@@ -167,7 +971,29 @@ func TestEnv(t *testing.T) {
 
 	// Clear environment, and ensure it is no longer bound.
 	Reset()
-	if v := EvalString("foo"); v != sym {
+	if v := EvalString("foo"); v.Equal(unbound) != T {
 		t.Fatalf("foo was already bound: %s", v)
 	}
 }
+
+func TestSelfQuoting(t *testing.T) {
+	defer func() { SelfQuoting = false }()
+	SelfQuoting = true
+
+	testCases := []struct {
+		expr string
+		want string
+	}{
+		{"foo", "foo"},
+		{"(cons a b)", "(a . b)"},
+		{"((lambda (a) (cons a b)) c)", "(c . b)"},
+		{"(error something went wrong)", "#[error: something went wrong]"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			if got := EvalString(tc.expr); got.String() != tc.want {
+				t.Errorf("want %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
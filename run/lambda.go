@@ -0,0 +1,89 @@
+package run
+
+import (
+	"whitehouse.id.au/microlisp/compile"
+	"whitehouse.id.au/microlisp/value"
+)
+
+// lambdaList describes the parameters of a function, with the initial
+// values of its optional and keyword parameters analysed.
+type lambdaList struct {
+	*compile.LambdaList
+	optional []proc
+	keys     []proc
+	special  map[string]bool // parameters bound dynamically, if any
+}
+
+// parseLambdaList parses the lambda list of a lambda special form,
+// analysing the initial values of optional parameters within the
+// scope of the parameters preceding them. It also returns the
+// environment for analysing the body, within the scope of every
+// parameter.
+func parseLambdaList(expr value.Value, env value.Environment) (*lambdaList, value.Environment) {
+	params := &lambdaList{LambdaList: compile.ParseLambdaList(expr)}
+	params.special = specialNames(params.Names())
+	scope := lexicalEnv(env, params.Required)
+	analyzeInit := func(param compile.Param) proc {
+		init := analyze(param.Init, scope, false)
+		names := []string{param.Name}
+		if param.Supplied != "" {
+			names = append(names, param.Supplied)
+		}
+		scope = lexicalEnv(scope, names)
+		return init
+	}
+	for _, param := range params.Optional {
+		params.optional = append(params.optional, analyzeInit(param))
+	}
+	if params.Rest != "" {
+		scope = lexicalEnv(scope, []string{params.Rest})
+	}
+	for _, param := range params.Keys {
+		params.keys = append(params.keys, analyzeInit(param))
+	}
+	return params, scope
+}
+
+// bind returns a new environment, extending env, where the parameters
+// are bound to the arguments. Initial values of optional parameters
+// are evaluated in this environment, and so may refer to parameters
+// preceding them. Special parameters are instead bound dynamically,
+// and added to bindings. The function is named by name in errors.
+func (l *lambdaList) bind(name string, env value.Environment, args []value.Value, bindings *dynamicBindings) value.Environment {
+	optional, rest, keys := l.Match(name, args)
+
+	extEnv := value.NewEnv(env)
+	define := func(name string, v value.Value) {
+		if l.special[name] {
+			bindings.bind(name, v)
+			return
+		}
+		extEnv.Define(name, v)
+	}
+	bindParam := func(param compile.Param, init proc, arg value.Value) {
+		supplied := T
+		if arg == nil {
+			arg = value.Primary(init(extEnv))
+			supplied = NIL
+		}
+		define(param.Name, arg)
+		if param.Supplied != "" {
+			define(param.Supplied, supplied)
+		}
+	}
+
+	for i, name := range l.Required {
+		define(name, args[i])
+	}
+	for i, param := range l.Optional {
+		bindParam(param, l.optional[i], optional[i])
+	}
+	if l.Rest != "" {
+		define(l.Rest, rest)
+	}
+	for i, param := range l.Keys {
+		bindParam(param, l.keys[i], keys[i])
+	}
+
+	return extEnv
+}
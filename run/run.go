@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 
+	"whitehouse.id.au/microlisp/compile"
 	"whitehouse.id.au/microlisp/read"
 	"whitehouse.id.au/microlisp/scan"
 	"whitehouse.id.au/microlisp/value"
@@ -23,6 +24,17 @@ func Run(r io.Reader, w io.Writer) error {
 func run(r io.Reader, w io.Writer, prompt string) error {
 	scanner := scan.New(bufio.NewReader(r))
 	reader := read.New(scanner)
+
+	// Errors raised while restarts are established are debugged
+	// using the same input.
+	defer func(debugger func(*value.Condition)) { value.Debugger = debugger }(value.Debugger)
+	value.Debugger = func(c *value.Condition) {
+		debug(reader, w, prompt, c)
+	}
+
+	// The most recent condition, for the backtrace command.
+	var last *value.Condition
+
 	for {
 		io.WriteString(w, prompt)
 
@@ -34,12 +46,44 @@ func run(r io.Reader, w io.Writer, prompt string) error {
 		if err, ok := v.(value.Error); ok {
 			return err
 		}
+		if v == backtraceCommand {
+			printBacktrace(w, last)
+			continue
+		}
 
-		// Evaluate the expression.
+		// Evaluate the expression, and print the result.
 		result := Eval(v)
+		if c, ok := result.(*value.Condition); ok {
+			last = c
+		}
+		print(w, result)
+	}
+}
+
+// backtraceCommand prints the backtrace of the most recent condition.
+var backtraceCommand = value.Intern("backtrace")
 
-		// Print the result.
-		fmt.Fprintln(w, result.String())
+// printBacktrace writes each call in progress when a condition was
+// raised, with the form that made the call.
+func printBacktrace(w io.Writer, c *value.Condition) {
+	if c == nil {
+		return
+	}
+	for i, frame := range c.Backtrace {
+		fmt.Fprintf(w, "  %d: %s\n", i, frame)
+	}
+}
+
+// print writes each value of a result on its own line. A result of no
+// values is written as a comment, so that it is not mistaken for no
+// result at all.
+func print(w io.Writer, result value.Value) {
+	vs := value.MultipleValues(result)
+	if len(vs) == 0 {
+		fmt.Fprintln(w, "; no values")
+	}
+	for _, v := range vs {
+		fmt.Fprintln(w, v.String())
 	}
 }
 
@@ -53,3 +97,100 @@ func Load(filename string) error {
 
 	return run(file, os.Stdout, "")
 }
+
+// debug offers a choice of the established restarts to recover from
+// an error. A restart is chosen by entering its name or number, and
+// then the value of each of its parameters. Any other expression is
+// evaluated, except for the backtrace command.
+// Choosing abort, or the end of the input, returns to the REPL, where
+// the error is printed.
+func debug(reader *read.Reader, w io.Writer, prompt string, c *value.Condition) {
+	restarts := value.Restarts()
+	fmt.Fprintln(w, c.String())
+	fmt.Fprintln(w, "Restarts:")
+	for i, r := range restarts {
+		fmt.Fprintf(w, "  %d: %s\n", i, r.Name)
+	}
+	fmt.Fprintf(w, "  %d: abort\n", len(restarts))
+
+	if prompt != "" {
+		prompt = "debug" + prompt
+	}
+	for {
+		io.WriteString(w, prompt)
+
+		v := reader.Read()
+		if _, ok := v.(value.Error); ok {
+			return
+		}
+
+		if v == backtraceCommand {
+			printBacktrace(w, c)
+			continue
+		}
+
+		var chosen *value.Restart
+		if i, ok := v.(value.Fixnum); ok && i >= 0 && int(i) <= len(restarts) {
+			if int(i) == len(restarts) {
+				return
+			}
+			chosen = restarts[i]
+		}
+		if sym, ok := v.(*value.Atom); ok {
+			if sym.Name == "abort" {
+				return
+			}
+			chosen, _ = value.FindRestart(sym.Name)
+		}
+		if chosen == nil {
+			print(w, Eval(v))
+			continue
+		}
+
+		// A restart is invoked by evaluation, so that an error
+		// is reported rather than raised here.
+		args, ok := readRestartArgs(reader, w, prompt, chosen)
+		if !ok {
+			return
+		}
+		call := []value.Value{value.Intern("invoke-restart"), chosen}
+		for _, arg := range args {
+			call = append(call, value.List([]value.Value{value.Intern("quote"), arg}))
+		}
+		print(w, Eval(value.List(call)))
+	}
+}
+
+// readRestartArgs prompts for the value of each required parameter of
+// a restart, and evaluates an expression read for it. It reports
+// whether the values were read before the end of the input. If an
+// error is raised by an expression, it is printed, rather than
+// debugged, and the value is prompted for again.
+func readRestartArgs(reader *read.Reader, w io.Writer, prompt string, r *value.Restart) ([]value.Value, bool) {
+	defer func(debugger func(*value.Condition)) { value.Debugger = debugger }(value.Debugger)
+	value.Debugger = nil
+
+	var params []string
+	if r.LambdaList() != NIL {
+		params = compile.ParseLambdaList(r.LambdaList()).Required
+	}
+
+	var args []value.Value
+	for i := 0; i < len(params); {
+		if prompt != "" {
+			fmt.Fprintf(w, "%s: ", params[i])
+		}
+		v := reader.Read()
+		if _, ok := v.(value.Error); ok {
+			return nil, false
+		}
+		result := Eval(v)
+		if c, ok := result.(*value.Condition); ok {
+			print(w, c)
+			continue
+		}
+		args = append(args, value.Primary(result))
+		i++
+	}
+	return args, true
+}
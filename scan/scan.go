@@ -6,7 +6,9 @@ package scan
 import (
 	"fmt"
 	"io"
+	"strconv"
 	"unicode"
+	"unicode/utf8"
 )
 
 // Type identifies the lexical token types of Lisp data.
@@ -20,8 +22,14 @@ const (
 	Comment
 
 	Atom
+	String
+	Char
 	LeftParen
 	RightParen
+
+	Quasiquote      // `
+	Unquote         // ,
+	UnquoteSplicing // ,@
 )
 
 // Token represents a token or literal
@@ -34,7 +42,7 @@ func (t Token) String() string {
 	switch t.Type {
 	case Error:
 		return fmt.Sprintf("error: %s", t.Text)
-	case Atom, Comment:
+	case Atom, String, Char, Comment:
 		return fmt.Sprintf("%v: %q", t.Type, t.Text)
 	}
 	return t.Type.String()
@@ -59,13 +67,118 @@ func (s *Scanner) readChar() {
 
 func (s *Scanner) lexAtom() Token {
 	var text []rune
-	for s.ch != eof && s.ch != '(' && s.ch != ')' && !unicode.IsSpace(s.ch) {
+	for s.ch != eof && !isDelimiter(s.ch) && !unicode.IsSpace(s.ch) {
 		text = append(text, s.ch)
 		s.readChar()
 	}
 	return Token{Type: Atom, Text: string(text)}
 }
 
+// isDelimiter reports whether a character terminates an atom.
+func isDelimiter(ch rune) bool {
+	switch ch {
+	case '(', ')', '`', ',', '"':
+		return true
+	}
+	return false
+}
+
+// lexString reads a string literal enclosed in double quotes, and
+// returns its characters with escape sequences replaced. A backslash
+// escapes a double quote or a backslash, introduces \n, \t and \r, or
+// a character given by its hexadecimal code point, as in \x41;.
+func (s *Scanner) lexString() Token {
+	var text []rune
+	s.readChar() // skip the opening quote
+	for s.ch != '"' {
+		switch s.ch {
+		case eof:
+			if s.err == io.EOF {
+				return Token{Type: Error, Text: "unterminated string"}
+			}
+			return Token{Type: Error, Text: s.err.Error()}
+		case '\\':
+			s.readChar()
+			ch, ok := s.lexEscape()
+			if !ok {
+				s.skipString()
+				return Token{Type: Error, Text: "invalid escape sequence in string"}
+			}
+			text = append(text, ch)
+		default:
+			text = append(text, s.ch)
+			s.readChar()
+		}
+	}
+	s.readChar() // skip the closing quote
+	return Token{Type: String, Text: string(text)}
+}
+
+// skipString skips the rest of a string literal that is in error, so
+// that scanning resumes after it.
+func (s *Scanner) skipString() {
+	for s.ch != '"' && s.ch != eof {
+		if s.ch == '\\' {
+			s.readChar()
+		}
+		s.readChar()
+	}
+	s.readChar()
+}
+
+// lexEscape reads the escape sequence following a backslash, and
+// reports whether it is valid.
+func (s *Scanner) lexEscape() (rune, bool) {
+	ch := s.ch
+	s.readChar()
+	switch ch {
+	case '"', '\\':
+		return ch, true
+	case 'n':
+		return '\n', true
+	case 't':
+		return '\t', true
+	case 'r':
+		return '\r', true
+	case 'x':
+		var hex []rune
+		for s.ch != ';' && s.ch != '"' && s.ch != eof {
+			hex = append(hex, s.ch)
+			s.readChar()
+		}
+		if s.ch != ';' {
+			return 0, false
+		}
+		s.readChar()
+		n, err := strconv.ParseUint(string(hex), 16, 32)
+		if err != nil || !utf8.ValidRune(rune(n)) {
+			return 0, false
+		}
+		return rune(n), true
+	}
+	return 0, false
+}
+
+// lexChar reads a character literal following #\, and returns the
+// text that names the character. The first character is always part
+// of the literal, so that #\( and #\space are both read.
+func (s *Scanner) lexChar() Token {
+	s.readChar() // skip the backslash
+	if s.ch == eof {
+		if s.err == io.EOF {
+			return Token{Type: Error, Text: "incomplete character literal"}
+		}
+		return Token{Type: Error, Text: s.err.Error()}
+	}
+	text := []rune{s.ch}
+	s.readChar()
+	for s.ch != eof && !isDelimiter(s.ch) && !unicode.IsSpace(s.ch) {
+		text = append(text, s.ch)
+		s.readChar()
+	}
+	return Token{Type: Char, Text: string(text)}
+}
+
 func (s *Scanner) lexComment() Token {
 	var text []rune
 	for s.ch != '\n' && s.ch != eof {
@@ -100,8 +213,28 @@ func (s *Scanner) Next() Token {
 	case ')':
 		s.readChar()
 		return Token{Type: RightParen}
+	case '`':
+		s.readChar()
+		return Token{Type: Quasiquote}
+	case ',':
+		s.readChar()
+		if s.ch == '@' {
+			s.readChar()
+			return Token{Type: UnquoteSplicing}
+		}
+		return Token{Type: Unquote}
 	case ';':
 		return s.lexComment()
+	case '"':
+		return s.lexString()
+	case '#':
+		s.readChar()
+		if s.ch == '\\' {
+			return s.lexChar()
+		}
+		tok := s.lexAtom()
+		tok.Text = "#" + tok.Text
+		return tok
 	case eof:
 		if s.err == io.EOF {
 			return Token{Type: EOF}
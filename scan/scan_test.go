@@ -43,6 +43,56 @@ func TestReader(t *testing.T) {
 			{Atom, "b"},
 			{RightParen, ""},
 		}},
+		{"`(a ,b ,@c)", []Token{
+			{Quasiquote, ""},
+			{LeftParen, ""},
+			{Atom, "a"},
+			{Unquote, ""},
+			{Atom, "b"},
+			{UnquoteSplicing, ""},
+			{Atom, "c"},
+			{RightParen, ""},
+		}},
+		{"a`b,c,@d", []Token{
+			{Atom, "a"},
+			{Quasiquote, ""},
+			{Atom, "b"},
+			{Unquote, ""},
+			{Atom, "c"},
+			{UnquoteSplicing, ""},
+			{Atom, "d"},
+		}},
+		{`("a b" "" "\"\\\n\t\r\x3bb;"a"c")`, []Token{
+			{LeftParen, ""},
+			{String, "a b"},
+			{String, ""},
+			{String, "\"\\\n\t\r\u03bb"},
+			{Atom, "a"},
+			{String, "c"},
+			{RightParen, ""},
+		}},
+		{"\"multiple\nlines\"", []Token{{String, "multiple\nlines"}}},
+		{`(#\a #\( #\space #\x41 a#b #foo)`, []Token{
+			{LeftParen, ""},
+			{Char, "a"},
+			{Char, "("},
+			{Char, "space"},
+			{Char, "x41"},
+			{Atom, "a#b"},
+			{Atom, "#foo"},
+			{RightParen, ""},
+		}},
+		{`#\`, []Token{{Error, "incomplete character literal"}}},
+		{`"unterminated`, []Token{{Error, "unterminated string"}}},
+		{`("\q" a "\x110000;" "\x41" b)`, []Token{
+			{LeftParen, ""},
+			{Error, "invalid escape sequence in string"},
+			{Atom, "a"},
+			{Error, "invalid escape sequence in string"},
+			{Error, "invalid escape sequence in string"},
+			{Atom, "b"},
+			{RightParen, ""},
+		}},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.expr, func(t *testing.T) {
@@ -4,9 +4,9 @@ package scan
 
 import "fmt"
 
-const _Type_name = "IllegalErrorEOFCommentAtomLeftParenRightParen"
+const _Type_name = "IllegalErrorEOFCommentAtomStringCharLeftParenRightParenQuasiquoteUnquoteUnquoteSplicing"
 
-var _Type_index = [...]uint8{0, 7, 12, 15, 22, 26, 35, 45}
+var _Type_index = [...]uint8{0, 7, 12, 15, 22, 26, 32, 36, 45, 55, 65, 72, 87}
 
 func (i Type) String() string {
 	if i < 0 || i >= Type(len(_Type_index)-1) {
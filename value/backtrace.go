@@ -0,0 +1,70 @@
+package value
+
+import "strings"
+
+// Frame describes a call of a Lisp function in progress.
+type Frame struct {
+	Name string  // name of the function, or empty if it is anonymous
+	Args []Value // arguments of the call
+	Form Value   // form that made the call, or nil if it was called from Go
+}
+
+// The depth and length beyond which lists are elided when a frame is
+// written.
+const (
+	frameLevel  = 4
+	frameLength = 6
+)
+
+// String returns the call as a list of the function's name and the
+// arguments, followed by the form that made it, such as
+// (f a b) from (f x (car y)). Deeply nested and long lists are elided.
+func (f Frame) String() string {
+	name := f.Name
+	if name == "" {
+		name = "lambda"
+	}
+	call := Cons(Intern(name), List(f.Args))
+	s := abbreviate(call, frameLevel, frameLength)
+	if f.Form != nil {
+		s += " from " + abbreviate(f.Form, frameLevel, frameLength)
+	}
+	return s
+}
+
+// abbreviate returns the written representation of a value, where
+// lists nested more than level deep are written as #, and elements of
+// a list beyond length are written as ....
+func abbreviate(v Value, level, length int) string {
+	c, ok := v.(*Cell)
+	if !ok {
+		return v.String()
+	}
+	if level == 0 {
+		return "#"
+	}
+
+	strs := []string{}
+	for {
+		if len(strs) == length {
+			strs = append(strs, "...")
+			break
+		}
+		strs = append(strs, abbreviate(c.Car, level-1, length))
+		if c.Cdr == NIL {
+			break
+		}
+		cdr, ok := c.Cdr.(*Cell)
+		if !ok {
+			strs = append(strs, ".", abbreviate(c.Cdr, level-1, length))
+			break
+		}
+		c = cdr
+	}
+	return "(" + strings.Join(strs, " ") + ")"
+}
+
+// CallStack, if set, returns the calls in progress, from the innermost
+// outwards. It is set by the evaluator, so that a backtrace can be
+// attached to a condition when it is raised.
+var CallStack func() []Frame
@@ -0,0 +1,35 @@
+package value
+
+import "fmt"
+
+// Budget limits the number of steps taken by an evaluation, such as
+// the functions it applies.
+type Budget struct {
+	Max   int // maximum number of steps, or zero for no limit
+	steps int
+}
+
+// Exhausted reports whether the budget has been exhausted. A nil
+// budget has no limit, and so never is.
+func (b *Budget) Exhausted() bool {
+	return b != nil && b.Max > 0 && b.steps > b.Max
+}
+
+// Step counts a step of an evaluation, and stops the evaluation if the
+// budget is exhausted. The condition raised is not signalled, so it
+// cannot be handled, and is recovered from only by the caller of the
+// evaluation. A nil budget has no limit.
+func (b *Budget) Step() {
+	if b == nil || b.Max <= 0 {
+		return
+	}
+	b.steps++
+	if b.steps <= b.Max {
+		return
+	}
+	c := &Condition{Type: BudgetExhausted, Message: fmt.Sprintf("budget exhausted after %d steps", b.Max)}
+	if CallStack != nil {
+		c.Backtrace = CallStack()
+	}
+	panic(c)
+}
@@ -0,0 +1,77 @@
+package value
+
+func init() {
+	SystemEnvironment.Define("catch", &Macro{Name: "catch", Expander: FuncN(catchForm)})
+}
+
+// catcher is an established catch tag, with a function that transfers
+// control to its catch form.
+type catcher struct {
+	tag    Value
+	fn     Value
+	parent *catcher
+}
+
+// catchers are those established, from the innermost outwards.
+var catchers *catcher
+
+var bindCatchFn = FuncX(3, func(vs []Value) Value {
+	return bindCatch(vs[0], vs[1], vs[2])
+})
+
+// catchForm expands the catch macro:
+//
+//	(catch tag body1 ... bodyN)
+//
+// The body is evaluated with tag established, and if a value is thrown
+// to the tag, control is transferred to the catch, and the value is
+// that thrown. The form is:
+//
+//	((lambda (tag thunk)
+//	   (apply apply
+//	          (call/cc (lambda (k)
+//	                     (apply dynamic-wind
+//	                            (bind-catch tag (escape k identity)
+//	                                        (lambda () (list values (multiple-value-call list (thunk))))))))))
+//	 tag-form
+//	 (lambda () body1 ... bodyN))
+func catchForm(args []Value) Value {
+	if len(args) == 0 {
+		Raise(IllFormedSpecialForm, "ill-formed special form: %s", Cons(Intern("catch"), NIL))
+	}
+
+	tag, thunk, k := Intern("tag"), Intern("thunk"), Intern("k")
+	call := callWithEscape(k, thunk, bindCatchFn, tag, form(escapeFn, k, identityFn))
+	return form(form(lambdaSym, form(tag, thunk), call), args[0], lambda(NIL, List(args[1:])))
+}
+
+// bindCatch returns the arguments of a call to dynamic-wind, which
+// calls a thunk with a catch tag established.
+func bindCatch(tag, fn, thunk Value) Value {
+	inner := &catcher{tag: tag, fn: fn, parent: catchers}
+
+	var saved *catcher
+	before := FuncX(0, func([]Value) Value {
+		saved, catchers = catchers, inner
+		return NIL
+	})
+	after := FuncX(0, func([]Value) Value {
+		catchers = saved
+		return NIL
+	})
+	return List([]Value{before, thunk, after})
+}
+
+// throw transfers control to the innermost catch established with the
+// tag, which returns the value. Tags are compared by equal, as eq
+// compares values. If no such catch is established, a control error
+// is raised.
+func throw(tag, v Value) Value {
+	for c := catchers; c != nil; c = c.parent {
+		if equal(c.tag, tag) == T {
+			return invoke(c.fn, []Value{v})
+		}
+	}
+	Raise(ControlError, "throw: no catch for tag %s", tag)
+	panic("not possible")
+}
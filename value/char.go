@@ -0,0 +1,140 @@
+package value
+
+import (
+	"fmt"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Char is a character, which is a Unicode code point.
+type Char rune
+
+// charNames maps the names of characters that are written by name,
+// such as #\space, to the characters.
+var charNames = map[string]Char{
+	"null":      0x00,
+	"alarm":     0x07,
+	"backspace": 0x08,
+	"tab":       0x09,
+	"newline":   0x0a,
+	"return":    0x0d,
+	"escape":    0x1b,
+	"space":     0x20,
+	"delete":    0x7f,
+}
+
+// String returns the written representation of the character, such
+// as #\a. Characters with a name are written by name, and other
+// characters that are not printable by their hexadecimal code point,
+// such as #\x2028.
+func (c Char) String() string {
+	for name, x := range charNames {
+		if c == x {
+			return `#\` + name
+		}
+	}
+	if unicode.IsPrint(rune(c)) {
+		return `#\` + string(rune(c))
+	}
+	return fmt.Sprintf(`#\x%x`, rune(c))
+}
+
+// Equal returns T for the same character.
+func (c Char) Equal(cmp Value) Value {
+	if x, ok := cmp.(Char); ok && c == x {
+		return T
+	}
+	return NIL
+}
+
+// ParseChar returns the character written as #\ followed by text,
+// which is a single character, the name of a character, or x followed
+// by a hexadecimal code point. It reports whether the text is valid.
+func ParseChar(text string) (Char, bool) {
+	if r, size := utf8.DecodeRuneInString(text); size == len(text) && (r != utf8.RuneError || size > 1) {
+		return Char(r), true
+	}
+	if c, ok := charNames[text]; ok {
+		return c, true
+	}
+	if len(text) > 1 && text[0] == 'x' {
+		n, err := strconv.ParseUint(text[1:], 16, 32)
+		if err == nil && utf8.ValidRune(rune(n)) {
+			return Char(n), true
+		}
+	}
+	return 0, false
+}
+
+// assertChar raises an error unless a value passed to the primitive
+// named name is a character, and returns it.
+func assertChar(name string, v Value) rune {
+	c, ok := v.(Char)
+	if !ok {
+		Raise(NotACharacter, "%s: %s is not a character", name, v)
+	}
+	return rune(c)
+}
+
+// charToInteger returns the code point of a character.
+func charToInteger(v Value) Value {
+	return Fixnum(assertChar("char->integer", v))
+}
+
+// integerToChar returns the character of a code point.
+func integerToChar(v Value) Value {
+	assertInteger("integer->char", v)
+	if n, ok := v.(Fixnum); ok && n >= 0 && n <= unicode.MaxRune && utf8.ValidRune(rune(n)) {
+		return Char(n)
+	}
+	Raise(TypeError, "integer->char: %s is not a code point", v)
+	panic("not possible")
+}
+
+// charUpcase returns the upper case of a character.
+func charUpcase(v Value) Value {
+	return Char(unicode.ToUpper(assertChar("char-upcase", v)))
+}
+
+// charAlphabetic returns T if a character is a letter.
+func charAlphabetic(v Value) Value {
+	if unicode.IsLetter(assertChar("char-alphabetic?", v)) {
+		return T
+	}
+	return NIL
+}
+
+// charWhitespace returns T if a character is white space.
+func charWhitespace(v Value) Value {
+	if unicode.IsSpace(assertChar("char-whitespace?", v)) {
+		return T
+	}
+	return NIL
+}
+
+// charComparison returns a primitive that compares each character
+// with the next by code point, returning T if cmp is true for each
+// pair.
+func charComparison(name string, cmp func(a, b rune) bool) Function {
+	return funcAtLeast(1, func(vs []Value) Value {
+		result := T
+		prev := assertChar(name, vs[0])
+		for _, v := range vs[1:] {
+			c := assertChar(name, v)
+			if !cmp(prev, c) {
+				result = NIL
+			}
+			prev = c
+		}
+		return result
+	})
+}
+
+var (
+	charEqualFn        = charComparison("char=", func(a, b rune) bool { return a == b })
+	charLessFn         = charComparison("char<", func(a, b rune) bool { return a < b })
+	charGreaterFn      = charComparison("char>", func(a, b rune) bool { return a > b })
+	charLessEqualFn    = charComparison("char<=", func(a, b rune) bool { return a <= b })
+	charGreaterEqualFn = charComparison("char>=", func(a, b rune) bool { return a >= b })
+)
@@ -0,0 +1,387 @@
+package value
+
+import (
+	"fmt"
+)
+
+// ConditionType is a named type of condition. A condition of a type
+// is also of each of the type's ancestors.
+type ConditionType struct {
+	Name   string
+	Parent *ConditionType // nil for the condition type
+}
+
+var conditionTypes = map[string]*ConditionType{}
+
+// DefineConditionType returns a new condition type, which handlers may
+// refer to by name.
+func DefineConditionType(name string, parent *ConditionType) *ConditionType {
+	t := &ConditionType{Name: name, Parent: parent}
+	conditionTypes[name] = t
+	return t
+}
+
+// IsA reports whether t is the type u, or a descendant of u.
+func (t *ConditionType) IsA(u *ConditionType) bool {
+	for ; t != nil; t = t.Parent {
+		if t == u {
+			return true
+		}
+	}
+	return false
+}
+
+// The hierarchy of condition types, including the types of errors
+// raised by the runtime.
+var (
+	AnyCondition         = DefineConditionType("condition", nil)
+	Warning              = DefineConditionType("warning", AnyCondition)
+	SeriousCondition     = DefineConditionType("serious-condition", AnyCondition)
+	ErrorCondition       = DefineConditionType("error", SeriousCondition)
+	SimpleError          = DefineConditionType("simple-error", ErrorCondition)
+	ControlError         = DefineConditionType("control-error", ErrorCondition)
+	UnboundVariable      = DefineConditionType("unbound-variable", ErrorCondition)
+	ProgramError         = DefineConditionType("program-error", ErrorCondition)
+	ArityError           = DefineConditionType("arity-error", ProgramError)
+	IllFormedSpecialForm = DefineConditionType("ill-formed-special-form", ProgramError)
+	TypeError            = DefineConditionType("type-error", ErrorCondition)
+	NotAPair             = DefineConditionType("not-a-pair", TypeError)
+	NotAFunction         = DefineConditionType("not-a-function", TypeError)
+	NotANumber           = DefineConditionType("not-a-number", TypeError)
+	NotAString           = DefineConditionType("not-a-string", TypeError)
+	NotACharacter        = DefineConditionType("not-a-character", TypeError)
+	ArithmeticError      = DefineConditionType("arithmetic-error", ErrorCondition)
+	DivisionByZero       = DefineConditionType("division-by-zero", ArithmeticError)
+	StackOverflow        = DefineConditionType("stack-overflow", ErrorCondition)
+	BudgetExhausted      = DefineConditionType("budget-exhausted", SeriousCondition)
+)
+
+// Condition is a value that represents an exceptional situation, such
+// as an error.
+type Condition struct {
+	Type    *ConditionType
+	Message string
+
+	// Backtrace describes the calls in progress when the condition
+	// was raised, from the innermost outwards.
+	Backtrace []Frame
+}
+
+// String returns the written representation of a condition. All
+// errors are written as #[error: message], whatever their type.
+func (c *Condition) String() string {
+	name := c.Type.Name
+	if c.Type.IsA(ErrorCondition) {
+		name = "error"
+	}
+	return fmt.Sprintf("#[%s: %s]", name, c.Message)
+}
+
+// Error implements the error interface.
+func (c *Condition) Error() string {
+	return c.Message
+}
+
+// Equal implements the Value interface, and returns T for conditions
+// of the same type and message.
+func (c *Condition) Equal(cmp Value) Value {
+	if x, ok := cmp.(*Condition); ok && c.Type == x.Type && c.Message == x.Message {
+		return T
+	}
+	return NIL
+}
+
+// Errorf raises a simple error with a formatted message.
+func Errorf(format string, a ...interface{}) {
+	Raise(SimpleError, format, a...)
+}
+
+// Raise raises an error of a type with a formatted message.
+func Raise(t *ConditionType, format string, a ...interface{}) {
+	RaiseCondition(&Condition{Type: t, Message: fmt.Sprintf(format, a...)})
+}
+
+// RaiseCondition signals a condition, and if no handler takes control,
+// panics with the condition. If any restarts are established, the
+// Debugger is first given the opportunity to invoke one.
+func RaiseCondition(c *Condition) {
+	if c.Backtrace == nil && CallStack != nil {
+		c.Backtrace = CallStack()
+	}
+	Signal(c)
+	if Debugger != nil && restarts != nil {
+		Debugger(c)
+	}
+	panic(c)
+}
+
+// Debugger, if set, is called with an error that no handler took
+// control of, while restarts are established.
+var Debugger func(c *Condition)
+
+// handler is a handler of conditions of a type, established by
+// handler-bind or ignore-errors.
+type handler struct {
+	typ    *ConditionType
+	fn     func(c *Condition)
+	parent *handler
+}
+
+// handlers are those established, from the innermost outwards.
+var handlers *handler
+
+// Signal calls each established handler of the type of a condition,
+// from the innermost outwards. A handler declines to handle the
+// condition by returning, or takes control by a non-local exit, such
+// as invoking a continuation or a restart.
+//
+// While a handler is called, only the handlers established outside it
+// are in effect.
+func Signal(c *Condition) {
+	for h := handlers; h != nil; h = h.parent {
+		if c.Type.IsA(h.typ) {
+			h.call(c)
+		}
+	}
+}
+
+func (h *handler) call(c *Condition) {
+	saved := handlers
+	handlers = h.parent
+	defer func() { handlers = saved }()
+	h.fn(c)
+}
+
+// Restart is a way to continue from a condition, established by
+// restart-case.
+type Restart struct {
+	Name   string
+	fn     Value
+	parent *Restart
+}
+
+// restarts are those established, from the innermost outwards.
+var restarts *Restart
+
+func (r *Restart) String() string {
+	return fmt.Sprintf("#[restart %s]", r.Name)
+}
+
+// Equal implements the Value interface, and returns T for the same
+// restart.
+func (r *Restart) Equal(cmp Value) Value {
+	if x, ok := cmp.(*Restart); ok && r == x {
+		return T
+	}
+	return NIL
+}
+
+// Restarts returns the established restarts, from the innermost
+// outwards.
+func Restarts() []*Restart {
+	var rs []*Restart
+	for r := restarts; r != nil; r = r.parent {
+		rs = append(rs, r)
+	}
+	return rs
+}
+
+// FindRestart returns the innermost established restart with a name.
+func FindRestart(name string) (*Restart, bool) {
+	for r := restarts; r != nil; r = r.parent {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// LambdaList returns the parameters of the restart, or nil if they are
+// not known.
+func (r *Restart) LambdaList() Value {
+	if e, ok := r.fn.(*escapeFunc); ok {
+		if fn, ok := e.fn.(NamedFunction); ok {
+			return fn.LambdaList()
+		}
+	}
+	return NIL
+}
+
+// InvokeRestart transfers control to a restart, passing it arguments.
+func InvokeRestart(r *Restart, args []Value) Value {
+	return invoke(r.fn, args)
+}
+
+// trapped is raised as a panic by the handler established by
+// trapError.
+type trapped struct {
+	trap *handler
+	c    *Condition
+}
+
+// trapError returns the value of an invoked function. If an error is
+// raised, the error value is instead returned. Other panics, such as
+// those used to transfer control to a continuation, pass through.
+func trapError(fn Value) (v Value) {
+	saved := handlers
+	trap := &handler{typ: ErrorCondition, parent: handlers}
+	trap.fn = func(c *Condition) {
+		panic(&trapped{trap, c})
+	}
+	handlers = trap
+
+	defer func() {
+		handlers = saved
+		if r := recover(); r != nil {
+			t, ok := r.(*trapped)
+			if !ok || t.trap != trap {
+				panic(r)
+			}
+			v = t.c
+		}
+	}()
+	v = invoke(fn, []Value{})
+	return
+}
+
+// bindHandlers returns the arguments of a call to dynamic-wind, which
+// calls a thunk with handlers established. Each binding is a cons of
+// the name of a condition type and a function to handle it.
+func bindHandlers(bindings, thunk Value) Value {
+	// The first binding is the innermost, and so is tried first.
+	inner := handlers
+	vs := listValues(bindings)
+	for i := len(vs) - 1; i >= 0; i-- {
+		binding := vs[i].(*Cell)
+		h := &handler{typ: conditionType(binding.Car), parent: inner}
+		fn := binding.Cdr
+		h.fn = func(c *Condition) {
+			invoke(fn, []Value{c})
+		}
+		inner = h
+	}
+
+	var saved *handler
+	before := FuncX(0, func([]Value) Value {
+		saved, handlers = handlers, inner
+		return NIL
+	})
+	after := FuncX(0, func([]Value) Value {
+		handlers = saved
+		return NIL
+	})
+	return List([]Value{before, thunk, after})
+}
+
+// bindRestarts returns the arguments of a call to dynamic-wind, which
+// calls a thunk with restarts established. Each binding is a cons of
+// the name of a restart and a function to invoke it.
+func bindRestarts(bindings, thunk Value) Value {
+	inner := restarts
+	vs := listValues(bindings)
+	for i := len(vs) - 1; i >= 0; i-- {
+		binding := vs[i].(*Cell)
+		inner = &Restart{Name: binding.Car.(*Atom).Name, fn: binding.Cdr, parent: inner}
+	}
+
+	var saved *Restart
+	before := FuncX(0, func([]Value) Value {
+		saved, restarts = restarts, inner
+		return NIL
+	})
+	after := FuncX(0, func([]Value) Value {
+		restarts = saved
+		return NIL
+	})
+	return List([]Value{before, thunk, after})
+}
+
+// listValues returns the elements of a proper list.
+func listValues(list Value) []Value {
+	var vs []Value
+	if list != NIL {
+		list.(*Cell).Walk(func(v Value) {
+			vs = append(vs, v)
+		})
+	}
+	return vs
+}
+
+// conditionType returns the condition type named by a symbol.
+func conditionType(v Value) *ConditionType {
+	if sym, ok := v.(*Atom); ok {
+		if t, ok := conditionTypes[sym.Name]; ok {
+			return t
+		}
+	}
+	Raise(TypeError, "%s is not a condition type", v)
+	panic("not possible")
+}
+
+// makeCondition returns a condition of the type named by the first
+// value, with a message composed of the rest. Strings contribute their
+// characters, without quotes.
+func makeCondition(vs []Value) Value {
+	AssertArgsBetween(1, -1, len(vs))
+	t := conditionType(vs[0])
+	return &Condition{Type: t, Message: message(vs[1:])}
+}
+
+// conditionTypeOf returns the name of the type of a condition.
+func conditionTypeOf(v Value) Value {
+	return Intern(assertCondition(v).Type.Name)
+}
+
+// signal signals a condition, returning nil if no handler takes
+// control.
+func signal(v Value) Value {
+	Signal(assertCondition(v))
+	return NIL
+}
+
+// assertCondition raises an error unless a value is a condition.
+func assertCondition(v Value) *Condition {
+	c, ok := v.(*Condition)
+	if !ok {
+		Raise(TypeError, "%s is not a condition", v)
+	}
+	return c
+}
+
+// computeRestarts returns a list of the established restarts, from
+// the innermost outwards.
+func computeRestarts([]Value) Value {
+	rs := Restarts()
+	vs := make([]Value, len(rs))
+	for i, r := range rs {
+		vs[i] = r
+	}
+	return List(vs)
+}
+
+// findRestart returns the innermost established restart named by a
+// symbol, or nil if there is none.
+func findRestart(v Value) Value {
+	sym, ok := v.(*Atom)
+	if !ok {
+		Raise(TypeError, "The object %s is not a symbol", v)
+	}
+	if r, ok := FindRestart(sym.Name); ok {
+		return r
+	}
+	return NIL
+}
+
+// invokeRestart transfers control to a restart, or the innermost
+// established restart named by a symbol, passing it the remaining
+// arguments.
+func invokeRestart(vs []Value) Value {
+	AssertArgsBetween(1, -1, len(vs))
+	r, ok := vs[0].(*Restart)
+	if !ok {
+		if r, ok = findRestart(vs[0]).(*Restart); !ok {
+			Raise(ControlError, "no restart named %s is active", vs[0])
+		}
+	}
+	return InvokeRestart(r, vs[1:])
+}
@@ -0,0 +1,64 @@
+package value
+
+import "fmt"
+
+// CallCC is the call-with-current-continuation primitive, which calls
+// a function with a continuation of the call.
+//
+// This implementation cannot capture the Go stack, so the
+// continuation may only be used to escape from the call. An evaluator
+// which manages its own flow of control may recognise CallCC, and
+// implement re-entrant continuations.
+var CallCC = Func1(callcc)
+
+// callcc calls fn with an escaping continuation, which raises a panic
+// that is recovered here.
+func callcc(fn Value) (v Value) {
+	k := &escape{active: true}
+	defer func() {
+		k.active = false
+		if r := recover(); r != nil {
+			e, ok := r.(*escaping)
+			if !ok || e.k != k {
+				panic(r)
+			}
+			v = e.v
+		}
+	}()
+	return invoke(fn, []Value{k})
+}
+
+// escape is a continuation that may only be invoked during the call
+// that captured it.
+type escape struct {
+	active bool
+}
+
+func (k *escape) String() string {
+	return fmt.Sprintf("#[continuation %p]", k)
+}
+
+// Equal implements the Value interface, and returns T for the same
+// continuation.
+func (k *escape) Equal(cmp Value) Value {
+	if x, ok := cmp.(*escape); ok && k == x {
+		return T
+	}
+	return NIL
+}
+
+// Invoke implements the Function interface.
+func (k *escape) Invoke(args []Value) Value {
+	AssertArgs(1, len(args))
+	if !k.active {
+		Raise(ControlError, "%s can no longer be resumed", k)
+	}
+	panic(&escaping{k, args[0]})
+}
+
+// escaping is raised as a panic to escape to the call that captured
+// a continuation.
+type escaping struct {
+	k *escape
+	v Value
+}
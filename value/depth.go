@@ -0,0 +1,34 @@
+package value
+
+// overflowing is set while a stack overflow is signalled, so that its
+// handlers may call functions beyond the maximum depth.
+var overflowing bool
+
+// expansions is the number of macro expansions being analysed or
+// compiled, each within the last.
+var expansions int
+
+// EnterExpansion records that the expansion of a macro is being
+// analysed or compiled, and returns a function to call when it has
+// been. An expansion nested within more than max others raises an
+// error, as a call would. A zero max is no limit.
+func EnterExpansion(max int) func() {
+	expansions++
+	leave := func() { expansions-- }
+	if max > 0 && expansions > max {
+		leave()
+		Raise(StackOverflow, "stack overflow (depth %d)", max+1)
+	}
+	return leave
+}
+
+// AssertDepth raises an error if a call would be nested depth calls
+// deep, which is more than max. A zero max is no limit.
+func AssertDepth(max, depth int) {
+	if max <= 0 || depth <= max || overflowing {
+		return
+	}
+	overflowing = true
+	defer func() { overflowing = false }()
+	Raise(StackOverflow, "stack overflow (depth %d)", depth)
+}
@@ -1,8 +1,8 @@
 package value
 
 import (
-	"errors"
 	"fmt"
+	"sort"
 )
 
 // An environment maintains a set of bindings that are typically
@@ -15,12 +15,23 @@ type Environment interface {
 	Lookup(name string) (Value, bool)
 	// Define a new symbol.
 	Define(name string, value Value)
-	// Update the value of a symbol.
+	// Update the value of a symbol. An Error is returned if the
+	// symbol is not bound.
 	Update(name string, value Value) error
 }
 
 var equalFn = Func2(equal)
 
+// Apply, IgnoreErrors and DynamicWind are the apply, ignore-errors and
+// dynamic-wind primitives. They are exported so that an evaluator
+// which manages its own flow of control can recognise and implement
+// them directly.
+var (
+	Apply        = FuncN(apply)
+	IgnoreErrors = Func1(trapError)
+	DynamicWind  = FuncX(3, dynamicWind)
+)
+
 // SystemEnvironment is the toplevel environment where primitives are
 // defined.
 var SystemEnvironment = &env{
@@ -37,13 +48,87 @@ var SystemEnvironment = &env{
 		"caddr":  Func1(caddr),
 		"cadar":  Func1(cadar),
 		"caddar": Func1(caddar),
-		"cons":   Func2(func(x, y Value) Value { return Cons(x, y) }),
-		"list":   FuncN(list),
-		"apply":  FuncN(apply),
+		"cons":   consFn,
+		"list":   listFn,
+		"apply":  Apply,
+		"values": valuesFn,
 
 		// Error Primitives
 		"error":         FuncN(raiseError),
-		"ignore-errors": Func1(trapError),
+		"ignore-errors": IgnoreErrors,
+		"dynamic-wind":  DynamicWind,
+		"throw":         Func2(throw),
+
+		// Condition Primitives
+		"make-condition":   FuncN(makeCondition),
+		"condition-type":   Func1(conditionTypeOf),
+		"signal":           Func1(signal),
+		"compute-restarts": FuncX(0, computeRestarts),
+		"find-restart":     Func1(findRestart),
+		"invoke-restart":   FuncN(invokeRestart),
+
+		// Number Primitives
+		"+":           FuncN(add),
+		"-":           funcAtLeast(1, subtract),
+		"*":           FuncN(multiply),
+		"/":           funcAtLeast(1, divide),
+		"quotient":    Func2(quotient),
+		"remainder":   Func2(remainder),
+		"mod":         Func2(modulo),
+		"=":           numEqualFn,
+		"<":           lessFn,
+		">":           greaterFn,
+		"<=":          lessEqualFn,
+		">=":          greaterEqualFn,
+		"zerop":       Func1(zerop),
+		"abs":         Func1(abs),
+		"expt":        Func2(expt),
+		"gcd":         FuncN(gcd),
+		"lcm":         FuncN(lcm),
+		"float":       Func1(float),
+		"floor":       floorFn,
+		"ceiling":     ceilingFn,
+		"round":       roundFn,
+		"truncate":    truncateFn,
+		"numerator":   Func1(numerator),
+		"denominator": Func1(denominator),
+
+		// String Primitives
+		"string-length":  Func1(stringLength),
+		"substring":      funcBetween(2, 3, substring),
+		"string-append":  FuncN(stringAppend),
+		"string=":        stringEqualFn,
+		"string<":        stringLessFn,
+		"string>":        stringGreaterFn,
+		"string<=":       stringLessEqualFn,
+		"string>=":       stringGreaterEqualFn,
+		"string-upcase":  Func1(stringUpcase),
+		"string-search":  Func2(stringSearch),
+		"string->symbol": Func1(stringToSymbol),
+		"symbol->string": Func1(symbolToString),
+		"string->list":   Func1(stringToList),
+		"number->string": Func1(numberToString),
+
+		// Character Primitives
+		"char->integer":    Func1(charToInteger),
+		"integer->char":    Func1(integerToChar),
+		"char-upcase":      Func1(charUpcase),
+		"char-alphabetic?": Func1(charAlphabetic),
+		"char-whitespace?": Func1(charWhitespace),
+		"char=":            charEqualFn,
+		"char<":            charLessFn,
+		"char>":            charGreaterFn,
+		"char<=":           charLessEqualFn,
+		"char>=":           charGreaterEqualFn,
+
+		// Function Primitives
+		"function-name":          Func1(functionName),
+		"function-lambda-list":   Func1(functionLambdaList),
+		"function-documentation": Func1(functionDocumentation),
+
+		// Control Primitives
+		"call-with-current-continuation": CallCC,
+		"call/cc":                        CallCC,
 
 		// Environment Primitives
 		"environment-bindings": EnvFunc(bindings),
@@ -56,7 +141,7 @@ func EnvFunc(fn func(Environment) Value) Function {
 	return Func1(func(v Value) Value {
 		env, ok := v.(Environment)
 		if !ok {
-			Errorf("%s is not an environment", v)
+			Raise(TypeError, "%s is not an environment", v)
 		}
 		return fn(env)
 	})
@@ -64,6 +149,22 @@ func EnvFunc(fn func(Environment) Value) Function {
 
 func init() {
 	SystemEnvironment.Define("system-environment", SystemEnvironment)
+
+	// Each primitive is named after the first name it is bound
+	// to, in order.
+	names := SystemEnvironment.Names()
+	sort.Strings(names)
+	for _, name := range names {
+		v, _ := SystemEnvironment.Lookup(name)
+		nameFunction(name, v)
+	}
+}
+
+// nameFunction names a primitive that has no name.
+func nameFunction(name string, v Value) {
+	if f, ok := v.(*nativeFunc); ok && f.name == "" {
+		f.name = name
+	}
 }
 
 // NewEnv returns a new environment that extends the bindings of
@@ -103,8 +204,12 @@ func (e *env) Names() []string {
 	return names
 }
 
-// Define implements the Environment interface.
+// Define implements the Environment interface. A primitive defined in
+// the system environment without a name is named after the variable.
 func (e *env) Define(name string, value Value) {
+	if e == SystemEnvironment {
+		nameFunction(name, value)
+	}
 	e.env[name] = value
 }
 
@@ -129,7 +234,7 @@ func (e *env) Update(name string, value Value) error {
 
 	// Only define can introduce new bindings.
 	if e.parent == nil {
-		return errors.New("no such binding")
+		return Error(fmt.Sprintf("unbound variable: %s", name))
 	}
 
 	return e.parent.Update(name, value)
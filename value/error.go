@@ -4,14 +4,11 @@ import (
 	"fmt"
 )
 
-// Error is a value used to represent runtime errors.
+// Error is a value used to represent errors that are returned rather
+// than raised, such as those of the reader. Errors raised at runtime
+// are conditions.
 type Error string
 
-// Errorf raises an error with a formatted message.
-func Errorf(format string, a ...interface{}) {
-	panic(Error(fmt.Sprintf(format, a...)))
-}
-
 // Error implements the error interface.
 func (e Error) Error() string {
 	return string(e)
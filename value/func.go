@@ -8,7 +8,7 @@ import (
 func invoke(v Value, args []Value) Value {
 	fn, ok := v.(Function)
 	if !ok {
-		Errorf("invoke: %s is not a function", v)
+		Raise(NotAFunction, "invoke: %s is not a function", v)
 	}
 	return fn.Invoke(args)
 }
@@ -18,64 +18,132 @@ type Function interface {
 	Invoke([]Value) Value
 }
 
+// NamedFunction is a function that describes itself.
+type NamedFunction interface {
+	Function
+	// Name returns the name of the function, or "" if it is
+	// anonymous.
+	Name() string
+	// LambdaList returns the parameters of the function.
+	LambdaList() Value
+	// Documentation returns the documentation string of the
+	// function, or "" if it has none.
+	Documentation() string
+}
+
 // FuncN creates a Function value from a native Go function that
 // accepts a variable number of arguments.
 func FuncN(fn func(vs []Value) Value) Function {
-	return &nativeFunc{fn: fn}
+	return &nativeFunc{min: 0, max: -1, fn: fn}
 }
 
 // Func1 creates a Function value from a native Go function that
 // accepts a single argument.
 func Func1(fn func(Value) Value) Function {
-	return FuncN(func(vs []Value) Value {
-		assertArgs(1, len(vs))
+	return &nativeFunc{min: 1, max: 1, fn: func(vs []Value) Value {
 		return fn(vs[0])
-	})
+	}}
 }
 
 // Func2 creates a Function value from a native Go function that
 // accepts two arguments.
 func Func2(fn func(Value, Value) Value) Function {
-	return FuncN(func(vs []Value) Value {
-		assertArgs(2, len(vs))
+	return &nativeFunc{min: 2, max: 2, fn: func(vs []Value) Value {
 		return fn(vs[0], vs[1])
-	})
+	}}
 }
 
 // FuncX creates a Function value from a native Go function that
 // accepts a specified number of arguments.
 func FuncX(n int, fn func([]Value) Value) Function {
-	return FuncN(func(vs []Value) Value {
-		assertArgs(n, len(vs))
-		return fn(vs)
-	})
+	return &nativeFunc{min: n, max: n, fn: fn}
 }
 
-func assertArgs(want, got int) {
-	if want == got {
-		return
+// funcAtLeast creates a Function value from a native Go function that
+// accepts at least n arguments.
+func funcAtLeast(n int, fn func([]Value) Value) Function {
+	return &nativeFunc{min: n, max: -1, fn: fn}
+}
+
+// funcBetween creates a Function value from a native Go function that
+// accepts between min and max arguments.
+func funcBetween(min, max int, fn func([]Value) Value) Function {
+	return &nativeFunc{min: min, max: max, fn: fn}
+}
+
+// AssertArgs raises an error unless a function requiring want
+// arguments was called with got arguments.
+func AssertArgs(want, got int) {
+	AssertArity("", want, want, got)
+}
+
+// AssertArgsBetween raises an error unless a function requiring
+// between min and max arguments was called with got arguments. If max
+// is negative, then there is no maximum.
+func AssertArgsBetween(min, max, got int) {
+	AssertArity("", min, max, got)
+}
+
+// AssertArity is AssertArgsBetween for the function named name, which
+// prefixes the message of the error unless it is empty.
+func AssertArity(name string, min, max, got int) {
+	raise := func(format string, args ...interface{}) {
+		if name != "" {
+			format = name + ": " + format
+		}
+		Raise(ArityError, format, args...)
 	}
+
 	switch {
-	case want == 1:
-		Errorf("called with %d arguments; requires exactly 1 argument", got)
-	case got == 1:
-		Errorf("called with 1 arguments; requires exactly %d arguments", want)
-	default:
-		Errorf("called with %d arguments; requires exactly %d arguments", got, want)
+	case min == max && min == got:
+		return
+	case min == max && min == 1:
+		raise("called with %d arguments; requires exactly 1 argument", got)
+	case min == max && got == 1:
+		raise("called with 1 arguments; requires exactly %d arguments", min)
+	case min == max:
+		raise("called with %d arguments; requires exactly %d arguments", got, min)
+	case got < min && max < 0:
+		raise("called with %s; requires at least %s", arguments(got), arguments(min))
+	case got < min || (max >= 0 && got > max):
+		raise("called with %s; requires between %d and %s", arguments(got), min, arguments(max))
 	}
 }
 
-// nativeFunc holds a native function that accepts a variable number
-// arugments.
+// AssertArgsOf raises an error unless fn accepts got arguments, for
+// an evaluator that implements a primitive directly.
+func AssertArgsOf(fn Function, got int) {
+	if f, ok := fn.(*nativeFunc); ok {
+		AssertArity(f.name, f.min, f.max, got)
+	}
+}
+
+// arguments returns a count of arguments, such as "1 argument".
+func arguments(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+	return fmt.Sprintf("%d arguments", n)
+}
+
+// nativeFunc holds a native function that accepts between min and
+// max arguments, where a negative max is no maximum. A primitive is
+// named when it is defined.
 type nativeFunc struct {
-	fn func([]Value) Value
+	name     string
+	min, max int
+	fn       func([]Value) Value
 }
 
 func (f *nativeFunc) String() string {
-	return fmt.Sprintf("#[compiled-function %p]", f)
+	if f.name == "" {
+		return fmt.Sprintf("#[compiled-function %p]", f)
+	}
+	return fmt.Sprintf("#[compiled-function %s]", f.name)
 }
 
 func (f *nativeFunc) Invoke(args []Value) Value {
+	AssertArity(f.name, f.min, f.max, len(args))
 	return f.fn(args)
 }
 
@@ -85,3 +153,83 @@ func (f *nativeFunc) Equal(cmp Value) Value {
 	}
 	return NIL
 }
+
+// Name implements the NamedFunction interface.
+func (f *nativeFunc) Name() string {
+	return f.name
+}
+
+// LambdaList implements the NamedFunction interface, describing the
+// arguments accepted, such as (arg1 &optional arg2 &rest args).
+func (f *nativeFunc) LambdaList() Value {
+	var params []Value
+	arg := func(i int) Value {
+		return Intern(fmt.Sprintf("arg%d", i+1))
+	}
+	for i := 0; i < f.min; i++ {
+		params = append(params, arg(i))
+	}
+	switch {
+	case f.max < 0:
+		params = append(params, Intern("&rest"), Intern("args"))
+	case f.max > f.min:
+		params = append(params, Intern("&optional"))
+		for i := f.min; i < f.max; i++ {
+			params = append(params, arg(i))
+		}
+	}
+	return List(params)
+}
+
+// Documentation implements the NamedFunction interface. Primitives
+// have no documentation.
+func (f *nativeFunc) Documentation() string {
+	return ""
+}
+
+// CompoundString returns the printed representation of a function
+// created by the lambda special form, such as
+// #[compound-function f (x)]. An anonymous function is named lambda.
+func CompoundString(name string, lambdaList Value) string {
+	if name == "" {
+		name = "lambda"
+	}
+	return fmt.Sprintf("#[compound-function %s %s]", name, lambdaList)
+}
+
+// functionName returns the symbol naming a function, or nil if it is
+// anonymous.
+func functionName(v Value) Value {
+	if fn, ok := assertFunction("function-name", v).(NamedFunction); ok && fn.Name() != "" {
+		return Intern(fn.Name())
+	}
+	return NIL
+}
+
+// functionLambdaList returns the lambda list of a function, or nil if
+// it is not known.
+func functionLambdaList(v Value) Value {
+	if fn, ok := assertFunction("function-lambda-list", v).(NamedFunction); ok {
+		return fn.LambdaList()
+	}
+	return NIL
+}
+
+// functionDocumentation returns the documentation string of a
+// function, or nil if it has none.
+func functionDocumentation(v Value) Value {
+	if fn, ok := assertFunction("function-documentation", v).(NamedFunction); ok && fn.Documentation() != "" {
+		return String(fn.Documentation())
+	}
+	return NIL
+}
+
+// assertFunction raises an error unless a value passed to the
+// primitive named name is a function.
+func assertFunction(name string, v Value) Function {
+	fn, ok := v.(Function)
+	if !ok {
+		Raise(NotAFunction, "%s: %s is not a function", name, v)
+	}
+	return fn
+}
@@ -0,0 +1,237 @@
+package value
+
+import "fmt"
+
+func init() {
+	SystemEnvironment.Define("handler-case", &Macro{Name: "handler-case", Expander: FuncN(handlerCase)})
+	SystemEnvironment.Define("handler-bind", &Macro{Name: "handler-bind", Expander: FuncN(handlerBind)})
+	SystemEnvironment.Define("restart-case", &Macro{Name: "restart-case", Expander: FuncN(restartCase)})
+}
+
+var (
+	// shortcuts for the forms of expansions
+	lambdaSym = Intern("lambda")
+	quoteSym  = Intern("quote")
+
+	consFn     = Func2(func(x, y Value) Value { return Cons(x, y) })
+	listFn     = FuncN(List)
+	identityFn = Func1(func(v Value) Value { return v })
+)
+
+// form returns a list of values, as a form to be evaluated.
+func form(vs ...Value) Value {
+	return List(vs)
+}
+
+// quote returns the form (quote v).
+func quote(v Value) Value {
+	return form(quoteSym, v)
+}
+
+// handlerCase expands the handler-case macro:
+//
+//	(handler-case form (type ([var]) body1 ... bodyN) ...)
+//
+// If a condition of one of the types is signalled while evaluating
+// form, control is transferred to the handler-case, and the value is
+// that of the clause's body, with var bound to the condition.
+func handlerCase(args []Value) Value {
+	checkExpr := func(ok bool) {
+		if !ok {
+			Raise(IllFormedSpecialForm, "ill-formed special form: %s", Cons(Intern("handler-case"), List(args)))
+		}
+	}
+	checkExpr(len(args) > 0)
+
+	var names, fns []Value
+	var ignore []bool
+	for _, clause := range args[1:] {
+		cell, ok := clause.(*Cell)
+		checkExpr(ok)
+		rest, ok := cell.Cdr.(*Cell)
+		checkExpr(ok)
+		params, ok := rest.Car.(*Cell)
+		checkExpr(rest.Car == NIL || ok && params.Cdr == NIL)
+
+		names = append(names, cell.Car)
+		fns = append(fns, lambda(rest.Car, rest.Cdr))
+		ignore = append(ignore, rest.Car == NIL)
+	}
+	return callWithEscapes(bindHandlersFn, args[0], names, fns, ignore)
+}
+
+// restartCase expands the restart-case macro:
+//
+//	(restart-case form (name lambda-list body1 ... bodyN) ...)
+//
+// If one of the restarts is invoked while evaluating form, control is
+// transferred to the restart-case, and the value is that of the
+// clause's body, with its parameters bound to the arguments.
+func restartCase(args []Value) Value {
+	checkExpr := func(ok bool) {
+		if !ok {
+			Raise(IllFormedSpecialForm, "ill-formed special form: %s", Cons(Intern("restart-case"), List(args)))
+		}
+	}
+	checkExpr(len(args) > 0)
+
+	var names, fns []Value
+	for _, clause := range args[1:] {
+		cell, ok := clause.(*Cell)
+		checkExpr(ok)
+		_, ok = cell.Car.(*Atom)
+		checkExpr(ok)
+		rest, ok := cell.Cdr.(*Cell)
+		checkExpr(ok)
+
+		names = append(names, cell.Car)
+		fns = append(fns, lambda(rest.Car, rest.Cdr))
+	}
+	return callWithEscapes(bindRestartsFn, args[0], names, fns, make([]bool, len(fns)))
+}
+
+// lambda returns a lambda form. An empty body is nil.
+func lambda(params, body Value) Value {
+	if body == NIL {
+		body = form(NIL)
+	}
+	return Cons(lambdaSym, Cons(params, body))
+}
+
+var (
+	bindHandlersFn = Func2(bindHandlers)
+	bindRestartsFn = Func2(bindRestarts)
+	escapeFn       = Func2(escapeTo)
+	ignoreArgsFn   = Func1(ignoreArgs)
+)
+
+// callWithEscapes returns a form that evaluates form with functions
+// bound by bind, which is either bindHandlers or bindRestarts. Each
+// bound function transfers control out of form, to call one of fns
+// with its arguments, or with none if ignore is set. The form is:
+//
+//	((lambda (thunk fn1 ... fnN)
+//	   (apply apply
+//	          (call/cc (lambda (k)
+//	                     (apply dynamic-wind
+//	                            (bind (list (cons (quote name1) (escape k fn1)) ...)
+//	                                  (lambda () (list values (multiple-value-call list (thunk))))))))))
+//	 (lambda () form)
+//	 fn1 ... fnN)
+//
+// The continuation receives a function and a list of its arguments,
+// so that the function is called after control is transferred, and
+// every value of form is returned. User
+// code is only evaluated outside the lambda, so cannot refer to its
+// variables.
+//
+// Functions are constants in the form, so that the expansion does not
+// depend on the definitions of the environment.
+func callWithEscapes(bind Function, body Value, names, fns []Value, ignore []bool) Value {
+	thunk, k := Intern("thunk"), Intern("k")
+
+	params := []Value{thunk}
+	bindings := []Value{listFn}
+	for i, name := range names {
+		fn := Intern(fmt.Sprintf("fn%d", i+1))
+		params = append(params, fn)
+
+		escape := form(escapeFn, k, fn)
+		if ignore[i] {
+			escape = form(ignoreArgsFn, escape)
+		}
+		bindings = append(bindings, form(consFn, quote(name), escape))
+	}
+
+	call := callWithEscape(k, thunk, bind, List(bindings))
+	return List(append([]Value{form(lambdaSym, List(params), call), lambda(NIL, form(body))}, fns...))
+}
+
+// callWithEscape returns a form that calls thunk with the continuation
+// k captured, where bind returns the arguments of a call to
+// dynamic-wind given args and a thunk. The continuation receives a
+// function and the list of its arguments. The form is:
+//
+//	(apply apply
+//	       (call/cc (lambda (k)
+//	                  (apply dynamic-wind
+//	                         (bind args... (lambda () (list values (multiple-value-call list (thunk)))))))))
+func callWithEscape(k, thunk Value, bind Function, args ...Value) Value {
+	args = append(args, form(lambdaSym, NIL, form(listFn, valuesFn, form(multipleValueCallSym, listFn, form(thunk)))))
+	return form(Apply, Apply,
+		form(CallCC, form(lambdaSym, form(k),
+			form(Apply, DynamicWind, form(append([]Value{bind}, args...)...)))))
+}
+
+// escapeTo returns a function that transfers control to a
+// continuation k, which receives fn and the list of arguments to call
+// it with.
+func escapeTo(k, fn Value) Value {
+	return &escapeFunc{k: k, fn: fn}
+}
+
+// escapeFunc is a function made by escapeTo.
+type escapeFunc struct {
+	k, fn Value
+}
+
+func (e *escapeFunc) String() string {
+	return fmt.Sprintf("#[compiled-function %p]", e)
+}
+
+// Equal implements the Value interface, and returns T for the same
+// function.
+func (e *escapeFunc) Equal(cmp Value) Value {
+	if x, ok := cmp.(*escapeFunc); ok && e == x {
+		return T
+	}
+	return NIL
+}
+
+// Invoke implements the Function interface.
+func (e *escapeFunc) Invoke(args []Value) Value {
+	return invoke(e.k, []Value{List([]Value{e.fn, List(args)})})
+}
+
+// ignoreArgs returns a function that calls fn without arguments.
+func ignoreArgs(fn Value) Value {
+	return FuncN(func([]Value) Value {
+		return invoke(fn, []Value{})
+	})
+}
+
+// handlerBind expands the handler-bind macro:
+//
+//	(handler-bind ((type handler) ...) body1 ... bodyN)
+//
+// Each handler is called with a condition of its type that is
+// signalled while evaluating the body, without transferring control.
+// The form is:
+//
+//	(apply dynamic-wind
+//	       (bind-handlers (list (cons (quote type) handler) ...)
+//	                      (lambda () body1 ... bodyN)))
+func handlerBind(args []Value) Value {
+	checkExpr := func(ok bool) {
+		if !ok {
+			Raise(IllFormedSpecialForm, "ill-formed special form: %s", Cons(Intern("handler-bind"), List(args)))
+		}
+	}
+	checkExpr(len(args) > 0)
+
+	bindings := []Value{listFn}
+	if args[0] != NIL {
+		list, ok := args[0].(*Cell)
+		checkExpr(ok)
+		list.Walk(func(v Value) {
+			binding, ok := v.(*Cell)
+			checkExpr(ok)
+			rest, ok := binding.Cdr.(*Cell)
+			checkExpr(ok && rest.Cdr == NIL)
+			bindings = append(bindings, form(consFn, quote(binding.Car), rest.Car))
+		})
+	}
+
+	return form(Apply, DynamicWind,
+		form(bindHandlersFn, List(bindings), lambda(NIL, List(args[1:]))))
+}
@@ -31,7 +31,7 @@ func (c *Cell) Walk(fn func(Value)) {
 
 		next, ok := cur.Cdr.(*Cell)
 		if !ok {
-			Errorf("cannot evaluate an improper list: %s", c)
+			Raise(ProgramError, "cannot evaluate an improper list: %s", c)
 		}
 		cur = next
 	}
@@ -52,7 +52,36 @@ func (c *Cell) Equal(cmp Value) Value {
 	return T
 }
 
+// abbreviations maps the symbols of forms such as (quasiquote x) to
+// the prefix they are written with.
+var abbreviations = map[*Atom]string{
+	Intern("quasiquote"):       "`",
+	Intern("unquote"):          ",",
+	Intern("unquote-splicing"): ",@",
+}
+
+// abbreviation returns the abbreviated prefix if c is a form such as
+// (quasiquote x).
+func (c *Cell) abbreviation() (string, bool) {
+	sym, ok := c.Car.(*Atom)
+	if !ok {
+		return "", false
+	}
+	prefix, ok := abbreviations[sym]
+	if !ok {
+		return "", false
+	}
+	if cdr, ok := c.Cdr.(*Cell); !ok || cdr.Cdr != NIL {
+		return "", false
+	}
+	return prefix, true
+}
+
 func (c *Cell) String() string {
+	if prefix, ok := c.abbreviation(); ok {
+		return prefix + c.Cdr.(*Cell).Car.String()
+	}
+
 	var buf bytes.Buffer
 
 	buf.WriteByte('(')
@@ -64,9 +93,10 @@ func (c *Cell) String() string {
 			break
 		}
 
-		// Check for an improper list.
+		// Check for an improper list. A list ending in an
+		// abbreviated form is also written in dotted notation.
 		cdr, ok := c.Cdr.(*Cell)
-		if !ok {
+		if !ok || isAbbreviation(cdr) {
 			buf.WriteString(" . ")
 			buf.WriteString(c.Cdr.String())
 			break
@@ -79,3 +109,8 @@ func (c *Cell) String() string {
 
 	return buf.String()
 }
+
+func isAbbreviation(c *Cell) bool {
+	_, ok := c.abbreviation()
+	return ok
+}
@@ -0,0 +1,82 @@
+package value
+
+import "fmt"
+
+// Macro is a value that transforms a form into another form, which
+// is then evaluated in place of the original.
+type Macro struct {
+	Name     string
+	Expander Function // called with the unevaluated arguments
+}
+
+func (m *Macro) String() string {
+	return fmt.Sprintf("#[macro %s]", m.Name)
+}
+
+// Equal implements the Value interface, and returns T for the same
+// macro.
+func (m *Macro) Equal(cmp Value) Value {
+	if x, ok := cmp.(*Macro); ok && m == x {
+		return T
+	}
+	return NIL
+}
+
+// Expand applies the macro's expander to the unevaluated arguments of
+// a form, returning the expansion.
+func (m *Macro) Expand(form *Cell) Value {
+	var args []Value
+	if form.Cdr != NIL {
+		cdr, ok := form.Cdr.(*Cell)
+		if !ok {
+			Raise(TypeError, "The object %s is not a list", form.Cdr)
+		}
+		cdr.Walk(func(v Value) {
+			args = append(args, v)
+		})
+	}
+	return Primary(m.Expander.Invoke(args))
+}
+
+// ExpandNested expands a form as Expand does, counting the expansion
+// as nested within those being analysed or compiled; see
+// [EnterExpansion].
+func (m *Macro) ExpandNested(form *Cell, max int) Value {
+	defer EnterExpansion(max)()
+	return m.Expand(form)
+}
+
+// MacroExpand1 expands form once if it is a call to a macro bound in
+// env. It reports whether an expansion took place.
+func MacroExpand1(form Value, env Environment) (Value, bool) {
+	cell, ok := form.(*Cell)
+	if !ok {
+		return form, false
+	}
+	sym, ok := cell.Car.(*Atom)
+	if !ok {
+		return form, false
+	}
+	v, ok := env.Lookup(sym.Name)
+	if !ok {
+		return form, false
+	}
+	m, ok := v.(*Macro)
+	if !ok {
+		return form, false
+	}
+	return m.Expand(cell), true
+}
+
+// MacroExpand repeatedly expands form until it is no longer a call to
+// a macro bound in env. It reports whether any expansion took place.
+func MacroExpand(form Value, env Environment) (Value, bool) {
+	expanded := false
+	for {
+		v, ok := MacroExpand1(form, env)
+		if !ok {
+			return form, expanded
+		}
+		form, expanded = v, true
+	}
+}
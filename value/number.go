@@ -0,0 +1,697 @@
+package value
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Fixnum is an integer small enough to be held in an int64.
+// Arithmetic on fixnums that overflows produces a Bignum, so that
+// integers are of unlimited size.
+type Fixnum int64
+
+func (n Fixnum) String() string {
+	return strconv.FormatInt(int64(n), 10)
+}
+
+// Equal implements the Value interface, and returns T for the same
+// integer.
+func (n Fixnum) Equal(cmp Value) Value {
+	if x, ok := cmp.(Fixnum); ok && n == x {
+		return T
+	}
+	return NIL
+}
+
+// Bignum is an integer too large to be held by a Fixnum. Its value is
+// never changed.
+type Bignum struct {
+	x *big.Int
+}
+
+func (n *Bignum) String() string {
+	return n.x.String()
+}
+
+// Equal implements the Value interface, and returns T for the same
+// integer.
+func (n *Bignum) Equal(cmp Value) Value {
+	if x, ok := cmp.(*Bignum); ok && n.x.Cmp(x.x) == 0 {
+		return T
+	}
+	return NIL
+}
+
+// Ratio is a rational number that is not an integer, such as 3/4. It
+// is held in lowest terms, and its value is never changed.
+type Ratio struct {
+	x *big.Rat
+}
+
+func (n *Ratio) String() string {
+	return n.x.String()
+}
+
+// Equal implements the Value interface, and returns T for the same
+// rational number.
+func (n *Ratio) Equal(cmp Value) Value {
+	if x, ok := cmp.(*Ratio); ok && n.x.Cmp(x.x) == 0 {
+		return T
+	}
+	return NIL
+}
+
+// Float is a double precision floating point number.
+type Float float64
+
+// String returns the written representation of a float, which always
+// has a decimal point or an exponent, such as 1.0 or 1.5e+10.
+// Infinities and NaN have no written representation, but arithmetic
+// raises an error rather than produce them.
+func (f Float) String() string {
+	x := float64(f)
+	if math.IsInf(x, 0) || math.IsNaN(x) {
+		return "#[float " + strconv.FormatFloat(x, 'g', -1, 64) + "]"
+	}
+	s := strconv.FormatFloat(x, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// Equal implements the Value interface, and returns T for the same
+// float.
+func (f Float) Equal(cmp Value) Value {
+	if x, ok := cmp.(Float); ok && f == x {
+		return T
+	}
+	return NIL
+}
+
+// NewBigInt returns an integer with the value of x, which is a Fixnum
+// if it is small enough. The integer may share x, which must not be
+// changed afterwards.
+func NewBigInt(x *big.Int) Value {
+	if x.IsInt64() {
+		return Fixnum(x.Int64())
+	}
+	return &Bignum{x}
+}
+
+// NewRat returns a rational number with the value of x, which is an
+// integer if x is. The number may share x, which must not be changed
+// afterwards.
+func NewRat(x *big.Rat) Value {
+	if x.IsInt() {
+		return NewBigInt(new(big.Int).Set(x.Num()))
+	}
+	return &Ratio{x}
+}
+
+// ErrNotNumber is returned by ParseNumber for text that is not written
+// as a number.
+var ErrNotNumber = errors.New("not a number")
+
+// ParseNumber returns the number written as text, such as -42, 3/4 or
+// 1.5e10. It returns ErrNotNumber if text is not a number, and an
+// error if it is a float too large to be represented.
+func ParseNumber(text string) (Value, error) {
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return Fixnum(n), nil
+	}
+
+	// digits returns the index following the digits that begin at
+	// i, and how many there are.
+	digits := func(i int) (int, int) {
+		n := i
+		for i < len(text) && text[i] >= '0' && text[i] <= '9' {
+			i++
+		}
+		return i, i - n
+	}
+	sign := func(i int) int {
+		if i < len(text) && (text[i] == '+' || text[i] == '-') {
+			i++
+		}
+		return i
+	}
+
+	i, whole := digits(sign(0))
+	if whole > 0 && i == len(text) {
+		x, _ := new(big.Int).SetString(text, 10)
+		return NewBigInt(x), nil
+	}
+
+	// An integer may be followed by a decimal point, such as 1.
+	if whole > 0 && text[i] == '.' && i+1 == len(text) {
+		return ParseNumber(text[:i])
+	}
+
+	// A ratio is an integer and a denominator, such as -3/4.
+	if whole > 0 && text[i] == '/' {
+		if j, n := digits(i + 1); n == 0 || j != len(text) {
+			return nil, ErrNotNumber
+		}
+		x, ok := new(big.Rat).SetString(text)
+		if !ok {
+			return nil, ErrNotNumber // the denominator is zero
+		}
+		return NewRat(x), nil
+	}
+
+	// A float has a fraction, an exponent, or both, such as 1.5,
+	// .5, 1e10 or 1.5e-3.
+	var fraction, exponent int
+	if i < len(text) && text[i] == '.' {
+		if i, fraction = digits(i + 1); fraction == 0 {
+			return nil, ErrNotNumber
+		}
+	}
+	if whole+fraction == 0 {
+		return nil, ErrNotNumber
+	}
+	if i < len(text) && (text[i] == 'e' || text[i] == 'E') {
+		if i, exponent = digits(sign(i + 1)); exponent == 0 {
+			return nil, ErrNotNumber
+		}
+	}
+	if i != len(text) || fraction+exponent == 0 {
+		return nil, ErrNotNumber
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("number out of range: %s", text)
+	}
+	return Float(f), nil
+}
+
+// Kinds of number, in order of contagion: arithmetic on two numbers
+// produces a number of the greater of their kinds.
+const (
+	notNumber = iota
+	integerKind
+	ratioKind
+	floatKind
+)
+
+// kindOf returns the kind of a number, or notNumber.
+func kindOf(v Value) int {
+	switch v.(type) {
+	case Fixnum, *Bignum:
+		return integerKind
+	case *Ratio:
+		return ratioKind
+	case Float:
+		return floatKind
+	}
+	return notNumber
+}
+
+// isNumber reports whether a value is a number.
+func isNumber(v Value) bool {
+	return kindOf(v) != notNumber
+}
+
+// assertNumber raises an error unless a value passed to the primitive
+// named name is a number, and returns its kind.
+func assertNumber(name string, v Value) int {
+	kind := kindOf(v)
+	if kind == notNumber {
+		Raise(NotANumber, "%s: %s is not a number", name, v)
+	}
+	return kind
+}
+
+// assertInteger raises an error unless a value passed to the primitive
+// named name is an integer, and returns its value.
+func assertInteger(name string, v Value) *big.Int {
+	switch n := v.(type) {
+	case Fixnum:
+		return big.NewInt(int64(n))
+	case *Bignum:
+		return n.x
+	}
+	Raise(NotANumber, "%s: %s is not an integer", name, v)
+	panic("not possible")
+}
+
+// assertRational raises an error unless a value passed to the
+// primitive named name is an integer or a ratio, and returns its
+// value.
+func assertRational(name string, v Value) *big.Rat {
+	if assertNumber(name, v) == floatKind {
+		Raise(NotANumber, "%s: %s is not a rational", name, v)
+	}
+	return toRat(v)
+}
+
+// toRat returns the value of an integer or a ratio.
+func toRat(v Value) *big.Rat {
+	switch n := v.(type) {
+	case Fixnum:
+		return new(big.Rat).SetInt64(int64(n))
+	case *Bignum:
+		return new(big.Rat).SetInt(n.x)
+	case *Ratio:
+		return n.x
+	}
+	panic("not possible")
+}
+
+// toFloat returns the value of a number as a float.
+func toFloat(v Value) float64 {
+	switch n := v.(type) {
+	case Fixnum:
+		return float64(n)
+	case *Bignum:
+		f, _ := new(big.Float).SetInt(n.x).Float64()
+		return f
+	case *Ratio:
+		f, _ := n.x.Float64()
+		return f
+	case Float:
+		return float64(n)
+	}
+	panic("not possible")
+}
+
+// newFloat returns the result of a float operation, and raises an
+// arithmetic error if it overflows or is undefined, such as a negative
+// number raised to a fractional power, rather than produce an infinity
+// or NaN.
+func newFloat(name string, f float64) Value {
+	if math.IsInf(f, 0) {
+		Raise(ArithmeticError, "%s: floating point overflow", name)
+	}
+	if math.IsNaN(f) {
+		Raise(ArithmeticError, "%s: undefined result", name)
+	}
+	return Float(f)
+}
+
+// arith is an arithmetic operation on two numbers, which is applied to
+// numbers converted to the greater of their kinds. The fixnum
+// operation reports false if the result overflows, in which case the
+// bignum operation is used. An operation without a bignum operation
+// applies the ratio operation to integers, and an operation without a
+// ratio operation only applies to integers.
+type arith struct {
+	name   string
+	fixnum func(x, y int64) (int64, bool)
+	bignum func(z, x, y *big.Int) *big.Int
+	ratio  func(z, x, y *big.Rat) *big.Rat
+	float  func(x, y float64) float64
+}
+
+func (op *arith) apply(a, b Value) Value {
+	if x, ok := a.(Fixnum); ok && op.fixnum != nil {
+		if y, ok := b.(Fixnum); ok {
+			if z, ok := op.fixnum(int64(x), int64(y)); ok {
+				return Fixnum(z)
+			}
+		}
+	}
+
+	kind := assertNumber(op.name, a)
+	if k := assertNumber(op.name, b); k > kind {
+		kind = k
+	}
+	if kind == integerKind && op.bignum == nil {
+		kind = ratioKind
+	}
+	if kind != integerKind && op.ratio == nil {
+		assertInteger(op.name, a)
+		assertInteger(op.name, b)
+	}
+
+	switch kind {
+	case floatKind:
+		return newFloat(op.name, op.float(toFloat(a), toFloat(b)))
+	case ratioKind:
+		return NewRat(op.ratio(new(big.Rat), toRat(a), toRat(b)))
+	}
+	return NewBigInt(op.bignum(new(big.Int), assertInteger(op.name, a), assertInteger(op.name, b)))
+}
+
+var (
+	addOp = &arith{
+		name: "+",
+		fixnum: func(x, y int64) (int64, bool) {
+			z := x + y
+			return z, (z > x) == (y > 0)
+		},
+		bignum: (*big.Int).Add,
+		ratio:  (*big.Rat).Add,
+		float:  func(x, y float64) float64 { return x + y },
+	}
+	subOp = &arith{
+		name: "-",
+		fixnum: func(x, y int64) (int64, bool) {
+			z := x - y
+			return z, (z < x) == (y > 0)
+		},
+		bignum: (*big.Int).Sub,
+		ratio:  (*big.Rat).Sub,
+		float:  func(x, y float64) float64 { return x - y },
+	}
+	mulOp = &arith{
+		name: "*",
+		fixnum: func(x, y int64) (int64, bool) {
+			if x == 0 || y == 0 {
+				return 0, true
+			}
+			z := x * y
+			return z, z/y == x && !(x == -1 && y == math.MinInt64) && !(y == -1 && x == math.MinInt64)
+		},
+		bignum: (*big.Int).Mul,
+		ratio:  (*big.Rat).Mul,
+		float:  func(x, y float64) float64 { return x * y },
+	}
+	divOp = &arith{
+		name:  "/",
+		ratio: (*big.Rat).Quo,
+		float: func(x, y float64) float64 { return x / y },
+	}
+)
+
+// add returns the sum of its arguments.
+func add(vs []Value) Value {
+	var sum Value = Fixnum(0)
+	for _, v := range vs {
+		sum = addOp.apply(sum, v)
+	}
+	return sum
+}
+
+// multiply returns the product of its arguments.
+func multiply(vs []Value) Value {
+	var product Value = Fixnum(1)
+	for _, v := range vs {
+		product = mulOp.apply(product, v)
+	}
+	return product
+}
+
+// subtract returns the first argument less the rest, or the negation
+// of a single argument.
+func subtract(vs []Value) Value {
+	if len(vs) == 1 {
+		if f, ok := vs[0].(Float); ok {
+			return -f // 0 - f would be 0.0 rather than -0.0
+		}
+		return subOp.apply(Fixnum(0), vs[0])
+	}
+	difference := vs[0]
+	for _, v := range vs[1:] {
+		difference = subOp.apply(difference, v)
+	}
+	return difference
+}
+
+// divide returns the first argument divided by the rest, or the
+// reciprocal of a single argument. The quotient of rational numbers
+// is exact.
+func divide(vs []Value) Value {
+	if len(vs) == 1 {
+		assertDivisor("/", vs[0])
+		return divOp.apply(Fixnum(1), vs[0])
+	}
+	quotient := vs[0]
+	for _, v := range vs[1:] {
+		assertDivisor("/", v)
+		quotient = divOp.apply(quotient, v)
+	}
+	return quotient
+}
+
+// assertDivisor raises an error if a divisor passed to the primitive
+// named name is zero.
+func assertDivisor(name string, v Value) {
+	if compare(name, v, Fixnum(0)) == 0 {
+		Raise(DivisionByZero, "%s: division by zero", name)
+	}
+}
+
+// quotient returns the quotient of two integers, truncated towards
+// zero.
+func quotient(a, b Value) Value {
+	assertInteger("quotient", a)
+	assertDivisor("quotient", b)
+	return (&arith{
+		name: "quotient",
+		fixnum: func(x, y int64) (int64, bool) {
+			return x / y, !(x == math.MinInt64 && y == -1)
+		},
+		bignum: (*big.Int).Quo,
+	}).apply(a, b)
+}
+
+// remainder returns the remainder of dividing two integers, which has
+// the sign of the dividend.
+func remainder(a, b Value) Value {
+	assertInteger("remainder", a)
+	assertDivisor("remainder", b)
+	return (&arith{
+		name: "remainder",
+		fixnum: func(x, y int64) (int64, bool) {
+			return x % y, true
+		},
+		bignum: (*big.Int).Rem,
+	}).apply(a, b)
+}
+
+// modulo returns the remainder of dividing two integers, rounding the
+// quotient down, so that it has the sign of the divisor.
+func modulo(a, b Value) Value {
+	assertInteger("mod", a)
+	assertDivisor("mod", b)
+	return (&arith{
+		name: "mod",
+		fixnum: func(x, y int64) (int64, bool) {
+			z := x % y
+			if z != 0 && (z < 0) != (y < 0) {
+				z += y
+			}
+			return z, true
+		},
+		bignum: func(z, x, y *big.Int) *big.Int {
+			z.Rem(x, y)
+			if z.Sign() != 0 && z.Sign() != y.Sign() {
+				z.Add(z, y)
+			}
+			return z
+		},
+	}).apply(a, b)
+}
+
+// compare returns -1, 0 or +1 as the number a is less than, equal to,
+// or greater than b. The name of the primitive comparing them is used
+// in errors.
+func compare(name string, a, b Value) int {
+	if x, ok := a.(Fixnum); ok {
+		if y, ok := b.(Fixnum); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return +1
+			}
+			return 0
+		}
+	}
+
+	kind := assertNumber(name, a)
+	if k := assertNumber(name, b); k > kind {
+		kind = k
+	}
+	switch kind {
+	case floatKind:
+		x, y := toFloat(a), toFloat(b)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return +1
+		}
+		return 0
+	case ratioKind:
+		return toRat(a).Cmp(toRat(b))
+	}
+	return assertInteger(name, a).Cmp(assertInteger(name, b))
+}
+
+// comparison returns a primitive that returns T if each of its
+// arguments is related to the next by cmp, which is given the result
+// of compare.
+func comparison(name string, cmp func(int) bool) Function {
+	return funcAtLeast(1, func(vs []Value) Value {
+		assertNumber(name, vs[0])
+		result := T
+		for i := 1; i < len(vs); i++ {
+			if !cmp(compare(name, vs[i-1], vs[i])) {
+				result = NIL
+			}
+		}
+		return result
+	})
+}
+
+var (
+	numEqualFn     = comparison("=", func(c int) bool { return c == 0 })
+	lessFn         = comparison("<", func(c int) bool { return c < 0 })
+	greaterFn      = comparison(">", func(c int) bool { return c > 0 })
+	lessEqualFn    = comparison("<=", func(c int) bool { return c <= 0 })
+	greaterEqualFn = comparison(">=", func(c int) bool { return c >= 0 })
+)
+
+// zerop returns T if a number is zero.
+func zerop(v Value) Value {
+	if compare("zerop", v, Fixnum(0)) == 0 {
+		return T
+	}
+	return NIL
+}
+
+// abs returns the absolute value of a number.
+func abs(v Value) Value {
+	if n, ok := v.(Fixnum); ok && n >= 0 {
+		return n
+	}
+	if compare("abs", v, Fixnum(0)) < 0 {
+		return subOp.apply(Fixnum(0), v)
+	}
+	return v
+}
+
+// expt returns a number raised to a power. A rational number raised to
+// an integer power is exact, and otherwise the result is a float.
+func expt(base, power Value) Value {
+	kind := assertNumber("expt", base)
+	if assertNumber("expt", power) != integerKind || kind == floatKind {
+		return newFloat("expt", math.Pow(toFloat(base), toFloat(power)))
+	}
+
+	y := assertInteger("expt", power)
+	if kind == integerKind && y.Sign() >= 0 {
+		return NewBigInt(new(big.Int).Exp(assertInteger("expt", base), y, nil))
+	}
+	if y.Sign() < 0 {
+		assertDivisor("expt", base)
+	}
+	x, n := toRat(base), new(big.Int).Abs(y)
+	num := new(big.Int).Exp(x.Num(), n, nil)
+	den := new(big.Int).Exp(x.Denom(), n, nil)
+	if y.Sign() < 0 {
+		num, den = den, num
+	}
+	return NewRat(new(big.Rat).SetFrac(num, den))
+}
+
+// gcd returns the greatest common divisor of its arguments, which is
+// zero if there are none.
+func gcd(vs []Value) Value {
+	z := new(big.Int)
+	for _, v := range vs {
+		z = new(big.Int).GCD(nil, nil, z, assertInteger("gcd", v))
+	}
+	return NewBigInt(z)
+}
+
+// lcm returns the least common multiple of its arguments, which is one
+// if there are none.
+func lcm(vs []Value) Value {
+	z := big.NewInt(1)
+	for _, v := range vs {
+		x := assertInteger("lcm", v)
+		if x.Sign() == 0 || z.Sign() == 0 {
+			z.SetInt64(0)
+			continue
+		}
+		d := new(big.Int).GCD(nil, nil, z, x)
+		z.Mul(z, new(big.Int).Quo(x, d))
+		z.Abs(z)
+	}
+	return NewBigInt(z)
+}
+
+// float converts a number to a float.
+func float(v Value) Value {
+	assertNumber("float", v)
+	return newFloat("float", toFloat(v))
+}
+
+// numerator returns the numerator of a rational number in lowest
+// terms.
+func numerator(v Value) Value {
+	return NewBigInt(new(big.Int).Set(assertRational("numerator", v).Num()))
+}
+
+// denominator returns the denominator of a rational number in lowest
+// terms, which is positive.
+func denominator(v Value) Value {
+	return NewBigInt(new(big.Int).Set(assertRational("denominator", v).Denom()))
+}
+
+// rounding returns a primitive that divides a number by an optional
+// divisor, which defaults to one, and rounds the quotient to an
+// integer. Its values are the integer and the remainder. The quotient
+// of rational numbers is rounded by exact, and otherwise by inexact.
+func rounding(name string, exact func(num, den *big.Int) *big.Int, inexact func(float64) float64) Function {
+	return funcBetween(1, 2, func(vs []Value) Value {
+		n, d := vs[0], Value(Fixnum(1))
+		if len(vs) == 2 {
+			d = vs[1]
+		}
+		assertDivisor(name, d)
+
+		var q Value
+		if assertNumber(name, n) == floatKind || kindOf(d) == floatKind {
+			f := inexact(toFloat(n) / toFloat(d))
+			if math.IsInf(f, 0) || math.IsNaN(f) {
+				Raise(ArithmeticError, "%s: %s cannot be rounded to an integer", name, Float(f))
+			}
+			i, _ := new(big.Float).SetFloat64(f).Int(nil)
+			q = NewBigInt(i)
+		} else {
+			x := new(big.Rat).Quo(toRat(n), toRat(d))
+			q = NewBigInt(exact(x.Num(), x.Denom()))
+		}
+		return values([]Value{q, subOp.apply(n, mulOp.apply(q, d))})
+	})
+}
+
+var (
+	floorFn = rounding("floor", func(num, den *big.Int) *big.Int {
+		// Euclidean division rounds down for a positive divisor.
+		return new(big.Int).Div(num, den)
+	}, math.Floor)
+
+	ceilingFn = rounding("ceiling", func(num, den *big.Int) *big.Int {
+		z := new(big.Int).Div(new(big.Int).Neg(num), den)
+		return z.Neg(z)
+	}, math.Ceil)
+
+	truncateFn = rounding("truncate", func(num, den *big.Int) *big.Int {
+		return new(big.Int).Quo(num, den)
+	}, math.Trunc)
+
+	// roundFn rounds to the nearest integer, and to the even integer
+	// if the quotient is halfway between two.
+	roundFn = rounding("round", func(num, den *big.Int) *big.Int {
+		z, m := new(big.Int).DivMod(num, den, new(big.Int))
+		switch m.Lsh(m, 1).Cmp(den) {
+		case +1:
+			z.Add(z, big.NewInt(1))
+		case 0:
+			if z.Bit(0) == 1 {
+				z.Add(z, big.NewInt(1))
+			}
+		}
+		return z
+	}, math.RoundToEven)
+)
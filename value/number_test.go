@@ -0,0 +1,84 @@
+package value
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestArithmetic(t *testing.T) {
+	maxInt, minInt := Fixnum(math.MaxInt64), Fixnum(math.MinInt64)
+	parse := func(text string) Value {
+		n, err := ParseNumber(text)
+		if err != nil {
+			t.Fatalf("%s is not a number: %v", text, err)
+		}
+		return n
+	}
+
+	testCases := []struct {
+		name string
+		fn   func() Value
+		want string
+	}{
+		{"max+1", func() Value { return add([]Value{maxInt, Fixnum(1)}) }, "9223372036854775808"},
+		{"min-1", func() Value { return subtract([]Value{minInt, Fixnum(1)}) }, "-9223372036854775809"},
+		{"-min", func() Value { return subtract([]Value{minInt}) }, "9223372036854775808"},
+		{"max*2", func() Value { return multiply([]Value{maxInt, Fixnum(2)}) }, "18446744073709551614"},
+		{"min*-1", func() Value { return multiply([]Value{minInt, Fixnum(-1)}) }, "9223372036854775808"},
+		{"-1*min", func() Value { return multiply([]Value{Fixnum(-1), minInt}) }, "9223372036854775808"},
+		{"min/-1", func() Value { return quotient(minInt, Fixnum(-1)) }, "9223372036854775808"},
+		{"abs min", func() Value { return abs(minInt) }, "9223372036854775808"},
+		{"(max+1)-1", func() Value { return subtract([]Value{parse("9223372036854775808"), Fixnum(1)}) }, "9223372036854775807"},
+		{"2^64", func() Value { return expt(Fixnum(2), Fixnum(64)) }, "18446744073709551616"},
+		{"mod big", func() Value { return modulo(parse("-18446744073709551617"), Fixnum(10)) }, "3"},
+		{"rem big", func() Value { return remainder(parse("-18446744073709551617"), Fixnum(10)) }, "-7"},
+		{"gcd big", func() Value { return gcd([]Value{parse("18446744073709551616"), Fixnum(-24)}) }, "8"},
+		{"lcm big", func() Value { return lcm([]Value{parse("18446744073709551616"), Fixnum(3)}) }, "55340232221128654848"},
+		{"compare", func() Value { return lessFn.Invoke([]Value{minInt, maxInt, parse("9223372036854775808")}) }, "t"},
+		{"-0.0", func() Value { return subtract([]Value{Float(0)}) }, "-0.0"},
+		{"trailing point", func() Value { return parse("-12.") }, "-12"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.fn()
+			if got.String() != tc.want {
+				t.Errorf("want %s, got %s", tc.want, got)
+			}
+			if _, ok := got.(*Bignum); ok {
+				if _, err := strconv.ParseInt(tc.want, 10, 64); err == nil {
+					t.Errorf("%s is a bignum, not a fixnum", got)
+				}
+			}
+		})
+	}
+}
+
+func TestNumberRoundTrip(t *testing.T) {
+	testCases := []Value{
+		Fixnum(0),
+		Fixnum(math.MinInt64),
+		multiply([]Value{Fixnum(math.MaxInt64), Fixnum(-3)}),
+		divide([]Value{Fixnum(-3), Fixnum(4)}),
+		divide([]Value{Fixnum(1), multiply([]Value{Fixnum(math.MaxInt64), Fixnum(3)})}),
+		Float(0),
+		Float(-1),
+		Float(0.1),
+		Float(1.5e10),
+		Float(1e21),
+		Float(-2.5e-7),
+		Float(math.MaxFloat64),
+		Float(math.SmallestNonzeroFloat64),
+	}
+	for _, want := range testCases {
+		t.Run(want.String(), func(t *testing.T) {
+			got, err := ParseNumber(want.String())
+			if err != nil {
+				t.Fatalf("%s is not read as a number: %v", want, err)
+			}
+			if got.Equal(want) != T {
+				t.Errorf("want %s (%T), got %s (%T)", want, want, got, got)
+			}
+		})
+	}
+}
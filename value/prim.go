@@ -1,13 +1,13 @@
 package value
 
-import (
-	"fmt"
-	"strings"
-)
-
-// atom returns T if the value is an atom.
+// atom returns T if the value is an atom, which is a symbol, a
+// number, a string or a character.
 func atom(arg Value) Value {
-	if _, ok := arg.(*Atom); ok {
+	switch arg.(type) {
+	case *Atom, String, Char:
+		return T
+	}
+	if isNumber(arg) {
 		return T
 	}
 	return NIL
@@ -28,7 +28,7 @@ func equal(a Value, b Value) Value {
 func car(arg Value) Value {
 	v, ok := arg.(*Cell)
 	if !ok {
-		Errorf("car: %s is not a pair", arg)
+		Raise(NotAPair, "car: %s is not a pair", arg)
 	}
 	return v.Car
 }
@@ -36,7 +36,7 @@ func car(arg Value) Value {
 func cdr(arg Value) Value {
 	v, ok := arg.(*Cell)
 	if !ok {
-		Errorf("cdr: %s is not a pair", arg)
+		Raise(NotAPair, "cdr: %s is not a pair", arg)
 	}
 	return v.Cdr
 }
@@ -65,7 +65,8 @@ func caddar(v Value) Value {
 	return car(cdr(cdr(car(v))))
 }
 
-func list(args []Value) Value {
+// List returns a proper list of values.
+func List(args []Value) Value {
 	if len(args) == 0 {
 		return NIL
 	}
@@ -78,16 +79,18 @@ func list(args []Value) Value {
 }
 
 // apply a list as arguments to a function.
-//
-// This might be simpler by implementing it in terms of invoke.
 func apply(vs []Value) Value {
-	if len(vs) < 1 {
-		Errorf("called with %d arguments; requires at least 1 argument", len(vs))
-	}
+	return invoke(SpreadArgs(vs))
+}
+
+// SpreadArgs returns the function and the arguments of a call to
+// apply, where the final argument is a list of further arguments.
+func SpreadArgs(vs []Value) (Value, []Value) {
+	AssertArity("apply", 1, -1, len(vs))
 
 	fn, rest := vs[0], vs[1:]
 	if len(rest) == 0 {
-		return invoke(vs[0], []Value{})
+		return fn, []Value{}
 	}
 
 	// Each initial argument is prepended onto the final cons cell.
@@ -98,15 +101,17 @@ func apply(vs []Value) Value {
 	}
 
 	// If the final cell is not a list, report error with all the arguments.
-	if _, ok := last.(*Cell); !ok {
-		Errorf("apply: improper argument list: %s", head)
+	if _, ok := last.(*Cell); !ok && last != NIL {
+		Raise(TypeError, "apply: improper argument list: %s", head)
 	}
 
-	var args []Value
-	head.(*Cell).Walk(func(v Value) {
-		args = append(args, v)
-	})
-	return invoke(fn, args)
+	args := []Value{}
+	if cell, ok := head.(*Cell); ok {
+		cell.Walk(func(v Value) {
+			args = append(args, v)
+		})
+	}
+	return fn, args
 }
 
 // bindings returns an association list mapping each defined symbol in
@@ -118,24 +123,29 @@ func bindings(env Environment) Value {
 		v, _ := env.Lookup(name)
 		bindings[i] = Cons(Intern(name), v)
 	}
-	return list(bindings)
+	return List(bindings)
 }
 
 // raiseError pretty-prints the values passed, and throws a
-// recoverable error.
+// recoverable error. Strings are printed without quotes, so that
+// (error "no such file:" name) reads naturally. A single condition is
+// raised as it is.
 func raiseError(vs []Value) Value {
-	Errorf(strings.Trim(fmt.Sprintf("%s", vs), "[]"))
+	if len(vs) == 1 {
+		if c, ok := vs[0].(*Condition); ok {
+			RaiseCondition(c)
+		}
+	}
+	Raise(SimpleError, "%s", message(vs))
 	panic("not possible")
 }
 
-// trapError returns the value of an invoked function. If an error is
-// raised, the error value is instead returned.
-func trapError(fn Value) (v Value) {
-	defer func() {
-		if r := recover(); r != nil {
-			v = r.(Error)
-		}
-	}()
-	v = invoke(fn, []Value{})
-	return
+// dynamicWind invokes the before, thunk and after functions in turn,
+// returning the value of thunk. The after function is invoked even if
+// thunk raises an error, or is otherwise exited by a panic.
+func dynamicWind(vs []Value) Value {
+	before, thunk, after := vs[0], vs[1], vs[2]
+	invoke(before, []Value{})
+	defer invoke(after, []Value{})
+	return invoke(thunk, []Value{})
 }
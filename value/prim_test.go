@@ -3,7 +3,7 @@ package value
 import "testing"
 
 func TestApply(t *testing.T) {
-	LIST := FuncN(list)
+	LIST := FuncN(List)
 	A, B, C, D := Intern("A"), Intern("B"), Intern("C"), Intern("D")
 
 	testCases := []struct {
@@ -11,13 +11,15 @@ func TestApply(t *testing.T) {
 		Want Value
 	}{
 		{[]Value{LIST}, NIL},
-		{[]Value{LIST, list([]Value{A})}, list([]Value{A})},
-		{[]Value{LIST, A, list([]Value{B})}, list([]Value{A, B})},
+		{[]Value{LIST, List([]Value{A})}, List([]Value{A})},
+		{[]Value{LIST, A, List([]Value{B})}, List([]Value{A, B})},
+		{[]Value{LIST, NIL}, NIL},
+		{[]Value{LIST, A, NIL}, List([]Value{A})},
 
-		{[]Value{LIST, A}, Error("apply: improper argument list: A")},
-		{[]Value{LIST, A, B}, Error("apply: improper argument list: (A . B)")},
-		{[]Value{LIST, A, B, C}, Error("apply: improper argument list: (A B . C)")},
-		{[]Value{LIST, A, B, C, D}, Error("apply: improper argument list: (A B C . D)")},
+		{[]Value{LIST, A}, &Condition{Type: TypeError, Message: "apply: improper argument list: A"}},
+		{[]Value{LIST, A, B}, &Condition{Type: TypeError, Message: "apply: improper argument list: (A . B)"}},
+		{[]Value{LIST, A, B, C}, &Condition{Type: TypeError, Message: "apply: improper argument list: (A B . C)"}},
+		{[]Value{LIST, A, B, C, D}, &Condition{Type: TypeError, Message: "apply: improper argument list: (A B C . D)"}},
 	}
 	for _, tc := range testCases {
 		got := trapError(FuncN(func(_ []Value) Value {
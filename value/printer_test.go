@@ -19,6 +19,23 @@ func TestValueString(t *testing.T) {
 		// Printing of improper lists.
 		{Cons(a, b), "(a . b)"},
 		{Cons(a, Cons(b, c)), "(a b . c)"},
+		// Printing of abbreviated forms.
+		{List([]Value{Intern("quasiquote"), a}), "`a"},
+		{List([]Value{Intern("unquote"), a}), ",a"},
+		{List([]Value{Intern("unquote-splicing"), a}), ",@a"},
+		{List([]Value{Intern("quasiquote"), List([]Value{a, List([]Value{Intern("unquote"), b})})}), "`(a ,b)"},
+		{Cons(a, List([]Value{Intern("unquote"), b})), "(a . ,b)"},
+		{List([]Value{Intern("unquote"), a, b}), "(unquote a b)"},
+		{List([]Value{Intern("unquote")}), "(unquote)"},
+		// Printing of strings.
+		{String(""), `""`},
+		{List([]Value{String("a b"), a}), `("a b" a)`},
+		{String("say \"hi\"\\\n"), `"say \"hi\"\\\n"`},
+		{String("\x00λ\u2028"), `"\x0;λ\x2028;"`},
+		// Printing of characters.
+		{List([]Value{Char('a'), Char('λ'), Char('('), Char('"')}), `(#\a #\λ #\( #\")`},
+		{List([]Value{Char(' '), Char('\n'), Char(0), Char(0x7f)}), `(#\space #\newline #\null #\delete)`},
+		{List([]Value{Char(0xa0), Char(0x2028)}), `(#\xa0 #\x2028)`},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.want, func(t *testing.T) {
@@ -29,3 +46,33 @@ func TestValueString(t *testing.T) {
 		})
 	}
 }
+
+func TestFrameString(t *testing.T) {
+	a, b, f := Intern("a"), Intern("b"), Intern("f")
+	nest := func(v Value, n int) Value {
+		for i := 0; i < n; i++ {
+			v = Cons(v, NIL)
+		}
+		return v
+	}
+
+	testCases := []struct {
+		frame Frame
+		want  string
+	}{
+		{Frame{Name: "f"}, "(f)"},
+		{Frame{Args: []Value{a}}, "(lambda a)"},
+		{Frame{Name: "f", Args: []Value{a, b}, Form: List([]Value{f, a, b})}, "(f a b) from (f a b)"},
+		{Frame{Name: "f", Args: []Value{nest(a, 4)}}, "(f (((#))))"},
+		{Frame{Name: "f", Args: []Value{Cons(a, b)}}, "(f (a . b))"},
+		{Frame{Name: "f", Args: []Value{List([]Value{a, a, a, a, a, a, a})}}, "(f (a a a a a a ...))"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.want, func(t *testing.T) {
+			got := tc.frame.String()
+			if tc.want != got {
+				t.Errorf("want:\n%s\ngot:\n%s", tc.want, got)
+			}
+		})
+	}
+}
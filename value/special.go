@@ -0,0 +1,52 @@
+package value
+
+// specials are the names of the variables declared special by defvar
+// or defparameter.
+var specials = map[string]bool{}
+
+// DeclareSpecial declares a variable special, so that its bindings are
+// dynamically scoped. A binding of a special variable is seen by every
+// form evaluated while the binding form is, rather than only by the
+// forms within it.
+func DeclareSpecial(name string) {
+	specials[name] = true
+}
+
+// ForgetSpecials forgets the declarations of special variables, so
+// that bindings of the variables are again lexically scoped. It is
+// used when the environment in which they were defined is discarded.
+func ForgetSpecials() {
+	specials = map[string]bool{}
+}
+
+// IsSpecial reports whether a variable has been declared special.
+func IsSpecial(name string) bool {
+	return specials[name]
+}
+
+// DynamicBinding is a binding of a special variable. While the binding
+// is in effect, it is the value of the variable in the environment,
+// and the binding holds the value it replaced.
+type DynamicBinding struct {
+	env  Environment
+	name string
+	v    Value
+}
+
+// NewDynamicBinding returns a binding of a special variable, bound in
+// env or an environment it inherits from, to a value. The binding is
+// not yet in effect.
+func NewDynamicBinding(env Environment, name string, v Value) *DynamicBinding {
+	return &DynamicBinding{env: env, name: name, v: v}
+}
+
+// Swap exchanges the value of the variable with that of the binding,
+// entering the binding if it is not in effect, or leaving it if it is.
+func (b *DynamicBinding) Swap() {
+	old, ok := b.env.Lookup(b.name)
+	if !ok {
+		Raise(UnboundVariable, "unbound variable: %s", b.name)
+	}
+	b.env.Update(b.name, b.v)
+	b.v = old
+}
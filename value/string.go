@@ -0,0 +1,187 @@
+package value
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// String is an immutable sequence of characters.
+type String string
+
+// String returns the written representation of the string, which is
+// enclosed in double quotes. Double quotes, backslashes, and
+// characters that are not printable are escaped.
+func (s String) String() string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range string(s) {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if unicode.IsPrint(r) {
+				b.WriteRune(r)
+			} else {
+				fmt.Fprintf(&b, `\x%x;`, r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// Equal returns T for a string with the same characters.
+func (s String) Equal(cmp Value) Value {
+	if x, ok := cmp.(String); ok && s == x {
+		return T
+	}
+	return NIL
+}
+
+// message composes the message of a condition from values, which are
+// separated by spaces. Strings contribute their characters, and other
+// values their written representation.
+func message(vs []Value) string {
+	parts := make([]string, len(vs))
+	for i, v := range vs {
+		if s, ok := v.(String); ok {
+			parts[i] = string(s)
+		} else {
+			parts[i] = v.String()
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// assertString raises an error unless a value passed to the primitive
+// named name is a string, and returns its characters.
+func assertString(name string, v Value) string {
+	s, ok := v.(String)
+	if !ok {
+		Raise(NotAString, "%s: %s is not a string", name, v)
+	}
+	return string(s)
+}
+
+// assertIndex raises an error unless a value passed to the primitive
+// named name is an index between 0 and max, and returns the index.
+func assertIndex(name string, v Value, max int) int {
+	assertInteger(name, v)
+	if i, ok := v.(Fixnum); ok && i >= 0 && int64(i) <= int64(max) {
+		return int(i)
+	}
+	Raise(TypeError, "%s: index %s is out of range", name, v)
+	panic("not possible")
+}
+
+// stringLength returns the number of characters in a string.
+func stringLength(v Value) Value {
+	return Fixnum(utf8.RuneCountInString(assertString("string-length", v)))
+}
+
+// substring returns the characters of a string from a start index up
+// to an optional end index, which defaults to the end of the string.
+func substring(vs []Value) Value {
+	rs := []rune(assertString("substring", vs[0]))
+	end := len(rs)
+	if len(vs) > 2 {
+		end = assertIndex("substring", vs[2], len(rs))
+	}
+	start := assertIndex("substring", vs[1], len(rs))
+	if start > end {
+		Raise(TypeError, "substring: start %d is greater than end %d", start, end)
+	}
+	return String(rs[start:end])
+}
+
+// stringAppend returns a string of the characters of each string in
+// turn.
+func stringAppend(vs []Value) Value {
+	var b strings.Builder
+	for _, v := range vs {
+		b.WriteString(assertString("string-append", v))
+	}
+	return String(b.String())
+}
+
+// stringComparison returns a primitive that compares each string with
+// the next, comparing their characters in turn by code point, and
+// returns T if cmp is true for each pair.
+func stringComparison(name string, cmp func(a, b string) bool) Function {
+	return funcAtLeast(1, func(vs []Value) Value {
+		result := T
+		prev := assertString(name, vs[0])
+		for _, v := range vs[1:] {
+			s := assertString(name, v)
+			if !cmp(prev, s) {
+				result = NIL
+			}
+			prev = s
+		}
+		return result
+	})
+}
+
+var (
+	stringEqualFn        = stringComparison("string=", func(a, b string) bool { return a == b })
+	stringLessFn         = stringComparison("string<", func(a, b string) bool { return a < b })
+	stringGreaterFn      = stringComparison("string>", func(a, b string) bool { return a > b })
+	stringLessEqualFn    = stringComparison("string<=", func(a, b string) bool { return a <= b })
+	stringGreaterEqualFn = stringComparison("string>=", func(a, b string) bool { return a >= b })
+)
+
+// stringUpcase returns a string with each character converted to
+// upper case.
+func stringUpcase(v Value) Value {
+	return String(strings.ToUpper(assertString("string-upcase", v)))
+}
+
+// stringSearch returns the index of the first occurrence of a pattern
+// in a string, or NIL if the pattern does not occur.
+func stringSearch(pattern, v Value) Value {
+	p := assertString("string-search", pattern)
+	s := assertString("string-search", v)
+	i := strings.Index(s, p)
+	if i < 0 {
+		return NIL
+	}
+	return Fixnum(utf8.RuneCountInString(s[:i]))
+}
+
+// stringToSymbol returns the symbol named by a string.
+func stringToSymbol(v Value) Value {
+	return Intern(assertString("string->symbol", v))
+}
+
+// symbolToString returns the name of a symbol.
+func symbolToString(v Value) Value {
+	sym, ok := v.(*Atom)
+	if !ok {
+		Raise(TypeError, "symbol->string: %s is not a symbol", v)
+	}
+	return String(sym.Name)
+}
+
+// stringToList returns a list of the characters in a string.
+func stringToList(v Value) Value {
+	var chars []Value
+	for _, r := range assertString("string->list", v) {
+		chars = append(chars, Char(r))
+	}
+	return List(chars)
+}
+
+// numberToString returns the written representation of a number.
+func numberToString(v Value) Value {
+	assertNumber("number->string", v)
+	return String(v.String())
+}
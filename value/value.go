@@ -34,6 +34,12 @@ func (v Atom) String() string {
 	return v.Name
 }
 
+// IsKeyword reports whether the symbol is a keyword, such as :foo,
+// which evaluates to itself.
+func (v *Atom) IsKeyword() bool {
+	return len(v.Name) > 1 && v.Name[0] == ':'
+}
+
 func (v *Atom) Equal(x Value) Value {
 	if _, ok := x.(*Atom); ok {
 		if v == x {
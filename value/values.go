@@ -0,0 +1,130 @@
+package value
+
+import "strings"
+
+func init() {
+	SystemEnvironment.Define("multiple-value-bind", &Macro{Name: "multiple-value-bind", Expander: FuncN(multipleValueBind)})
+	SystemEnvironment.Define("multiple-value-list", &Macro{Name: "multiple-value-list", Expander: FuncN(multipleValueList)})
+}
+
+// Values is the result of a form that returns other than exactly one
+// value. Only the primary value, which is the first or else nil, is
+// seen where a form's value is used as an argument, a test or the
+// value of a variable. The multiple-value-call special form receives
+// every value.
+type Values []Value
+
+func (vs Values) String() string {
+	strs := []string{"#[values"}
+	for _, v := range vs {
+		strs = append(strs, v.String())
+	}
+	return strings.Join(strs, " ") + "]"
+}
+
+// Equal implements the Value interface, and returns T if each value is
+// equal.
+func (vs Values) Equal(cmp Value) Value {
+	x, ok := cmp.(Values)
+	if !ok || len(x) != len(vs) {
+		return NIL
+	}
+	for i := range vs {
+		if vs[i].Equal(x[i]) == NIL {
+			return NIL
+		}
+	}
+	return T
+}
+
+// Primary returns the primary value of a result.
+func Primary(v Value) Value {
+	if vs, ok := v.(Values); ok {
+		if len(vs) == 0 {
+			return NIL
+		}
+		return vs[0]
+	}
+	return v
+}
+
+// MultipleValues returns every value of a result.
+func MultipleValues(v Value) []Value {
+	if vs, ok := v.(Values); ok {
+		return vs
+	}
+	return []Value{v}
+}
+
+// values returns its arguments as multiple values. A single value is
+// returned as it is.
+func values(vs []Value) Value {
+	if len(vs) == 1 {
+		return vs[0]
+	}
+	return Values(vs)
+}
+
+var (
+	valuesFn = FuncN(values)
+
+	multipleValueCallSym = Intern("multiple-value-call")
+)
+
+// multipleValueList expands the multiple-value-list macro:
+//
+//	(multiple-value-list form)
+//
+// The value is a list of every value of form. The form is:
+//
+//	(multiple-value-call list form)
+func multipleValueList(args []Value) Value {
+	if len(args) != 1 {
+		Raise(IllFormedSpecialForm, "ill-formed special form: %s", Cons(Intern("multiple-value-list"), List(args)))
+	}
+	return form(multipleValueCallSym, listFn, args[0])
+}
+
+// multipleValueBind expands the multiple-value-bind macro:
+//
+//	(multiple-value-bind (var1 ... varN) form body1 ... bodyN)
+//
+// The body is evaluated with each variable bound to the corresponding
+// value of form, or nil if there are fewer values. The form is:
+//
+//	(multiple-value-call (lambda (var1 ... varN) body1 ... bodyN)
+//	                     (multiple-value-call take-N form))
+//
+// where take-N returns exactly N values.
+func multipleValueBind(args []Value) Value {
+	checkExpr := func(ok bool) {
+		if !ok {
+			Raise(IllFormedSpecialForm, "ill-formed special form: %s", Cons(Intern("multiple-value-bind"), List(args)))
+		}
+	}
+	checkExpr(len(args) >= 2)
+
+	var vars []Value
+	if args[0] != NIL {
+		list, ok := args[0].(*Cell)
+		checkExpr(ok)
+		list.Walk(func(v Value) {
+			_, ok := v.(*Atom)
+			checkExpr(ok)
+			vars = append(vars, v)
+		})
+	}
+
+	n := len(vars)
+	take := FuncN(func(vs []Value) Value {
+		taken := make([]Value, n)
+		for i := range taken {
+			taken[i] = NIL
+		}
+		copy(taken, vs)
+		return values(taken)
+	})
+	return form(multipleValueCallSym,
+		lambda(List(vars), List(args[2:])),
+		form(multipleValueCallSym, take, args[1]))
+}
@@ -0,0 +1,83 @@
+package vm
+
+import (
+	"fmt"
+
+	"whitehouse.id.au/microlisp/value"
+)
+
+// Continuation is the rest of a computation, captured when the
+// machine calls value.CallCC. Invoking it with a value abandons the
+// current computation, and resumes the captured computation as if the
+// call had returned that value. It may be resumed any number of times,
+// even after the call has returned.
+type Continuation struct {
+	s     *state // run that captured the continuation
+	saved state
+}
+
+func (k *Continuation) String() string {
+	return fmt.Sprintf("#[continuation %p]", k)
+}
+
+// Equal implements the Value interface, and returns T for the same
+// continuation.
+func (k *Continuation) Equal(cmp value.Value) value.Value {
+	if x, ok := cmp.(*Continuation); ok && k == x {
+		return T
+	}
+	return NIL
+}
+
+// Invoke implements the Function interface. A continuation invoked
+// from Go is resumed by its run if that is still executing;
+// otherwise, it is resumed by a new run, which returns the value of
+// the resumed computation.
+func (k *Continuation) Invoke(args []value.Value) value.Value {
+	value.AssertArgs(1, len(args))
+	if k.s.active {
+		panic(&resumption{k, args[0]})
+	}
+	s := k.s.m.newState()
+	s.pending = func() { s.transfer(k, args[0]) }
+	return s.m.execute(s)
+}
+
+// resumption is raised as a panic to transfer control to a
+// continuation of a run that is executing, but is not the innermost.
+type resumption struct {
+	k *Continuation
+	v value.Value
+}
+
+// capture returns a continuation of the current state of the run.
+// The stack and calls are copied, as they will be changed by the run.
+func (s *state) capture() *Continuation {
+	return &Continuation{s: s, saved: state{
+		code:  s.code,
+		pc:    s.pc,
+		env:   s.env,
+		stack: append([]value.Value(nil), s.stack...),
+		calls: append([]activation(nil), s.calls...),
+		inv:   s.inv,
+		winds: s.winds,
+	}}
+}
+
+// transfer resumes a continuation, first leaving and entering
+// dynamic-wind calls so that those in progress are those of the
+// continuation.
+func (s *state) transfer(k *Continuation, v value.Value) {
+	s.rewind(k.saved.winds)
+	s.resume(k, v)
+}
+
+// resume replaces the state of the run with a copy of a captured
+// continuation, which receives the value v.
+func (s *state) resume(k *Continuation, v value.Value) {
+	s.code, s.pc, s.env = k.saved.code, k.saved.pc, k.saved.env
+	s.stack = append(append([]value.Value(nil), k.saved.stack...), v)
+	s.calls = append([]activation(nil), k.saved.calls...)
+	s.inv = k.saved.inv
+	s.winds = k.saved.winds
+}
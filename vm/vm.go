@@ -0,0 +1,570 @@
+// Package vm implements a stack machine that executes the bytecode
+// produced by package compile.
+package vm
+
+import (
+	"fmt"
+
+	"whitehouse.id.au/microlisp/compile"
+	"whitehouse.id.au/microlisp/value"
+)
+
+var (
+	// shortcuts for standard types
+	T   = value.T
+	NIL = value.NIL
+
+	unassigned = value.Error("#[unassigned]")
+
+	// missing occupies the slot of an optional parameter without
+	// an argument, until the prologue computes its initial value.
+	missing = value.Error("#[missing]")
+)
+
+// Machine executes compiled code. Global variables are bound in Env.
+type Machine struct {
+	Env value.Environment
+
+	// SelfQuoting enables the behaviour described by McCarthy's
+	// manual, where an unbound variable evaluates to its own
+	// symbol rather than raising an error.
+	SelfQuoting bool
+
+	// Budget, if not nil, limits the calls made by the machine.
+	Budget *value.Budget
+
+	// MaxDepth, if not zero, limits the depth of nested calls.
+	MaxDepth int
+
+	current *state // innermost run that is executing, if any
+}
+
+// Run executes the code of a top-level form, and returns its value.
+func (m *Machine) Run(code *compile.Code) value.Value {
+	s := m.newState()
+	s.code = code
+	return m.execute(s)
+}
+
+// frame holds the variables of a function call or a let form. The
+// variables of enclosing forms are found through the parent.
+type frame struct {
+	vars   []value.Value
+	parent *frame
+}
+
+// up returns the frame depth levels out from f.
+func (f *frame) up(depth int) *frame {
+	for ; depth > 0; depth-- {
+		f = f.parent
+	}
+	return f
+}
+
+// activation is the state of a caller, restored when a call returns.
+type activation struct {
+	code   *compile.Code
+	pc     int
+	env    *frame
+	inv    invocation
+	inline bool // whether the call is the expansion of a macro, in the same frame
+}
+
+// invocation describes the call that entered the code of an
+// activation, for backtraces.
+type invocation struct {
+	args []value.Value
+	site *compile.Code // code of the call, or nil if called from Go
+	pc   int           // address of the call in site
+}
+
+// state is the state of a run of the machine. Calls to closures of
+// the machine are made within a run, rather than by recursion, so that
+// the run holds the entire control state of a computation, which may
+// be captured as a continuation.
+//
+// A run is nested when a native function, such as a primitive written
+// in Go, invokes a closure.
+type state struct {
+	m     *Machine
+	code  *compile.Code
+	pc    int
+	env   *frame
+	stack []value.Value
+	calls []activation
+	inv   invocation // call that entered code
+	winds *wind      // dynamic-wind calls in progress
+
+	active  bool   // whether the run is executing
+	base    *wind  // dynamic-wind calls in progress when the run began
+	pending func() // transfer of control to complete before continuing
+	outer   *state // run that was executing when the run began
+	level   int    // depth of the call that began the run
+}
+
+// newState returns the state of a new run, which begins within the
+// dynamic-wind calls of the innermost executing run.
+func (m *Machine) newState() *state {
+	s := &state{m: m}
+	if m.current != nil {
+		s.winds = m.current.winds
+		s.level = m.current.level + len(m.current.calls) + 1
+	}
+	s.base = s.winds
+	return s
+}
+
+func (s *state) push(v value.Value) {
+	s.stack = append(s.stack, v)
+}
+
+func (s *state) pop() value.Value {
+	v := s.stack[len(s.stack)-1]
+	s.stack = s.stack[:len(s.stack)-1]
+	return v
+}
+
+func (s *state) top() value.Value {
+	return s.stack[len(s.stack)-1]
+}
+
+// primary replaces multiple values on top of the stack with the
+// primary value, and returns it.
+func (s *state) primary() value.Value {
+	v := value.Primary(s.top())
+	s.stack[len(s.stack)-1] = v
+	return v
+}
+
+// execute runs until the code of the initial activation returns. A
+// continuation captured by this run being resumed from a nested run
+// is recovered from without leaving the run.
+//
+// If the run is otherwise exited by a panic, then the after thunks of
+// dynamic-wind calls begun by the run are invoked first.
+func (m *Machine) execute(s *state) value.Value {
+	outer := m.current
+	m.current, s.active, s.outer = s, true, outer
+	defer func() {
+		if r := recover(); r != nil {
+			s.rewind(s.base)
+			m.current, s.active = outer, false
+			panic(r)
+		}
+		m.current, s.active = outer, false
+	}()
+
+	for {
+		if v, ok := s.run(); ok {
+			return v
+		}
+	}
+}
+
+// run executes instructions until the run is complete, reporting
+// whether it was. Otherwise, a panic was recovered, and the run
+// should continue.
+func (s *state) run() (v value.Value, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if !s.recover(r) {
+				panic(r)
+			}
+		}
+	}()
+
+	if s.pending != nil {
+		transfer := s.pending
+		s.pending = nil
+		transfer()
+	}
+
+	m := s.m
+	for {
+		instr := s.code.Instrs[s.pc]
+		s.pc++
+
+		switch instr.Op {
+		case compile.Const:
+			s.push(s.code.Consts[instr.A])
+
+		case compile.Local:
+			s.push(s.env.up(instr.A).vars[instr.B])
+
+		case compile.SetLocal:
+			s.env.up(instr.A).vars[instr.B] = s.primary()
+
+		case compile.Global:
+			s.push(m.global(s.code.Consts[instr.A].(*value.Atom)))
+
+		case compile.GlobalFunction:
+			v := m.global(s.code.Consts[instr.A].(*value.Atom))
+			mac, ok := v.(*value.Macro)
+			if !ok {
+				s.push(v)
+				break
+			}
+
+			// The expansion of a macro defined after the
+			// call was compiled is executed in place of the
+			// call. Unless the call is in tail position, it
+			// returns to the instruction after the call.
+			code := s.code.Expand(s.pc-1, mac, m.Env)
+			if s.code.Instrs[instr.B].Op == compile.Call {
+				s.calls = append(s.calls, activation{code: s.code, pc: instr.B + 1, env: s.env, inv: s.inv, inline: true})
+			}
+			s.code, s.pc = code, 0
+
+		case compile.SetGlobal:
+			sym := s.code.Consts[instr.A].(*value.Atom)
+			if err := m.Env.Update(sym.Name, s.primary()); err != nil {
+				value.Raise(value.UnboundVariable, "%s", err)
+			}
+
+		case compile.Define:
+			sym := s.code.Consts[instr.A].(*value.Atom)
+			m.Env.Define(sym.Name, s.primary())
+
+		case compile.Macro:
+			sym := s.code.Consts[instr.A].(*value.Atom)
+			s.push(&value.Macro{
+				Name:     sym.Name,
+				Expander: s.pop().(value.Function),
+			})
+
+		case compile.Pop:
+			s.pop()
+
+		case compile.Jump:
+			s.pc = instr.A
+
+		case compile.JumpIfNot:
+			if value.Primary(s.pop()) == NIL {
+				s.pc = instr.A
+			}
+
+		case compile.JumpIfKeep:
+			if s.primary() != NIL {
+				s.pc = instr.A
+			} else {
+				s.pop()
+			}
+
+		case compile.JumpIfNotKeep:
+			if s.primary() == NIL {
+				s.pc = instr.A
+			} else {
+				s.pop()
+			}
+
+		case compile.Closure:
+			s.push(&Closure{
+				Code: s.code.Consts[instr.A].(*compile.Code),
+				env:  s.env,
+				m:    m,
+			})
+
+		case compile.Call, compile.TailCall:
+			base := len(s.stack) - instr.A - 1
+			fn := value.Primary(s.stack[base])
+			args := make([]value.Value, instr.A)
+			for i, v := range s.stack[base+1:] {
+				args[i] = value.Primary(v)
+			}
+			s.stack = s.stack[:base]
+			s.call(fn, args, instr.Op == compile.TailCall)
+
+		case compile.Return:
+			if len(s.calls) == 0 {
+				return s.pop(), true
+			}
+			caller := s.calls[len(s.calls)-1]
+			s.calls = s.calls[:len(s.calls)-1]
+			s.code, s.pc, s.env, s.inv = caller.code, caller.pc, caller.env, caller.inv
+
+		case compile.Frame:
+			vars := make([]value.Value, instr.A)
+			n := copy(vars, s.stack[len(s.stack)-instr.B:])
+			for i := 0; i < n; i++ {
+				vars[i] = value.Primary(vars[i])
+			}
+			for i := n; i < len(vars); i++ {
+				vars[i] = unassigned
+			}
+			s.stack = s.stack[:len(s.stack)-instr.B]
+			s.env = &frame{vars: vars, parent: s.env}
+
+		case compile.PopFrame:
+			s.env = s.env.parent
+
+		case compile.Default:
+			if s.env.vars[instr.A] != missing {
+				s.pc = instr.B
+			}
+
+		case compile.Cons:
+			tail := value.Primary(s.pop())
+			s.push(value.Cons(value.Primary(s.pop()), tail))
+
+		case compile.Append:
+			tail := value.Primary(s.pop())
+			s.push(appendList(value.Primary(s.pop()), tail))
+
+		case compile.ValueList:
+			s.push(value.List(value.MultipleValues(s.pop())))
+
+		case compile.Bind:
+			sym := s.code.Consts[instr.A].(*value.Atom)
+			b := value.NewDynamicBinding(m.Env, sym.Name, value.Primary(s.pop()))
+			swap := value.FuncX(0, func([]value.Value) value.Value {
+				b.Swap()
+				return NIL
+			})
+			b.Swap()
+			s.winds = &wind{before: swap, after: swap, parent: s.winds}
+
+		case compile.Unbind:
+			for i := 0; i < instr.A; i++ {
+				w := s.winds
+				s.winds = w.parent
+				invoke(w.after, []value.Value{})
+			}
+
+		case compile.JumpIfBound:
+			sym := s.code.Consts[instr.A].(*value.Atom)
+			if _, ok := m.Env.Lookup(sym.Name); ok {
+				s.pc = instr.B
+			}
+
+		default:
+			panic(fmt.Sprintf("unknown instruction: %s", instr.Op))
+		}
+	}
+}
+
+// call applies a function to arguments. A closure of the machine is
+// entered, replacing the current activation if tail is true. Any
+// other function is invoked, and its value pushed.
+//
+// Primitives that affect the flow of control are implemented here,
+// so that the run holds the entire state of the computation.
+func (s *state) call(fn value.Value, args []value.Value, tail bool) {
+	s.m.Budget.Step()
+	for {
+		switch x := fn.(type) {
+		case *Closure:
+			if x.m != s.m {
+				break
+			}
+			inv := invocation{args: args, site: s.code, pc: s.pc - 1}
+			if !tail {
+				value.AssertDepth(s.m.MaxDepth, s.level+len(s.calls)+1)
+				s.calls = append(s.calls, activation{code: s.code, pc: s.pc, env: s.env, inv: s.inv})
+			}
+			s.code, s.pc, s.env, s.inv = x.Code, 0, x.bind(args), inv
+			return
+
+		case *Continuation:
+			value.AssertArgs(1, len(args))
+			if x.s != s && x.s.active {
+				// The continuation belongs to a run that
+				// invoked this one, which must resume it.
+				panic(&resumption{x, args[0]})
+			}
+			s.transfer(x, args[0])
+			return
+		}
+
+		switch fn {
+		case value.Apply:
+			fn, args = value.SpreadArgs(args)
+			continue
+
+		case value.CallCC:
+			value.AssertArgsOf(value.CallCC, len(args))
+			fn, args = args[0], []value.Value{s.capture()}
+			continue
+
+		case value.DynamicWind:
+			fn = &Closure{Code: dynamicWind, m: s.m}
+			continue
+
+		case windFn:
+			s.winds = &wind{before: args[0], after: args[1], parent: s.winds}
+			s.push(NIL)
+			return
+
+		case unwindFn:
+			s.winds = s.winds.parent
+			s.push(NIL)
+			return
+
+		case value.IgnoreErrors:
+			fn = &Closure{Code: ignoreErrors, m: s.m}
+			continue
+		}
+
+		s.push(invoke(fn, args))
+		return
+	}
+}
+
+// recover handles a panic raised during the run, reporting whether
+// the run may continue. Lisp code is not run while recovering, so
+// dynamic-wind thunks are left to be invoked by the pending transfer.
+func (s *state) recover(r interface{}) bool {
+	if r, ok := r.(*resumption); ok && r.k.s == s {
+		s.pending = func() { s.transfer(r.k, r.v) }
+		return true
+	}
+	return false
+}
+
+// global returns the value of a global variable.
+func (m *Machine) global(sym *value.Atom) value.Value {
+	v, ok := m.Env.Lookup(sym.Name)
+	if !ok {
+		if !m.SelfQuoting {
+			value.Raise(value.UnboundVariable, "unbound variable: %s", sym.Name)
+		}
+		return sym
+	}
+	return v
+}
+
+// invoke applies a list of arguments to a function.
+func invoke(v value.Value, args []value.Value) value.Value {
+	fn, ok := v.(value.Function)
+	if !ok {
+		value.Raise(value.NotAFunction, "invoke: %s is not a function", v)
+	}
+	return fn.Invoke(args)
+}
+
+// appendList returns a copy of a list, ending in tail rather than nil.
+func appendList(v, tail value.Value) value.Value {
+	if v == NIL {
+		return tail
+	}
+	list, ok := v.(*value.Cell)
+	if !ok {
+		value.Raise(value.TypeError, "The object %s is not a list", v)
+	}
+	var elems []value.Value
+	list.Walk(func(x value.Value) {
+		elems = append(elems, x)
+	})
+	for i := len(elems) - 1; i >= 0; i-- {
+		tail = value.Cons(elems[i], tail)
+	}
+	return tail
+}
+
+// Backtrace returns the calls of closures in progress, from the
+// innermost outwards, including those of the runs that invoked the
+// executing run.
+func (m *Machine) Backtrace() []value.Frame {
+	var frames []value.Frame
+	add := func(code *compile.Code, inv invocation) {
+		if code.Params == nil {
+			return // top-level form
+		}
+		frame := value.Frame{Name: code.Name, Args: inv.args}
+		if inv.site != nil {
+			frame.Form = inv.site.Forms[inv.pc]
+		}
+		frames = append(frames, frame)
+	}
+	for s := m.current; s != nil; s = s.outer {
+		add(s.code, s.inv)
+		for i := len(s.calls) - 1; i >= 0; i-- {
+			// The frame of an expansion's caller is
+			// already added for the expansion.
+			if !s.calls[i].inline {
+				add(s.calls[i].code, s.calls[i].inv)
+			}
+		}
+	}
+	return frames
+}
+
+// Closure is a function created by compiled code.
+type Closure struct {
+	Code *compile.Code
+	env  *frame // frame of definition
+	m    *Machine
+}
+
+func (c *Closure) String() string {
+	return value.CompoundString(c.Code.Name, c.Code.Params.Source)
+}
+
+// Equal implements the Value interface, and returns T for the same
+// closure.
+func (c *Closure) Equal(cmp value.Value) value.Value {
+	if x, ok := cmp.(*Closure); ok && c == x {
+		return T
+	}
+	return NIL
+}
+
+// Name implements the NamedFunction interface.
+func (c *Closure) Name() string {
+	return c.Code.Name
+}
+
+// LambdaList implements the NamedFunction interface.
+func (c *Closure) LambdaList() value.Value {
+	return c.Code.Params.Source
+}
+
+// Documentation implements the NamedFunction interface.
+func (c *Closure) Documentation() string {
+	return c.Code.Doc
+}
+
+// Invoke implements the Function interface, calling the closure in a
+// new run.
+func (c *Closure) Invoke(args []value.Value) value.Value {
+	c.m.Budget.Step()
+	s := c.m.newState()
+	value.AssertDepth(c.m.MaxDepth, s.level)
+	s.code, s.env, s.inv = c.Code, c.bind(args), invocation{args: args}
+	return c.m.execute(s)
+}
+
+// bind returns a new frame, extending the closure's frame, where the
+// parameters occupy slots in the order of the lambda list. Optional
+// parameters without arguments are left missing, for the code's
+// prologue to initialise.
+func (c *Closure) bind(args []value.Value) *frame {
+	params := c.Code.Params
+	optional, rest, keys := params.Match(c.Code.Name, args)
+
+	vars := make([]value.Value, c.Code.Size)
+	i := copy(vars, args[:len(params.Required)])
+	bindParam := func(param compile.Param, arg value.Value) {
+		supplied := T
+		if arg == nil {
+			arg, supplied = missing, NIL
+		}
+		vars[i] = arg
+		i++
+		if param.Supplied != "" {
+			vars[i] = supplied
+			i++
+		}
+	}
+	for j, param := range params.Optional {
+		bindParam(param, optional[j])
+	}
+	if params.Rest != "" {
+		vars[i] = rest
+		i++
+	}
+	for j, param := range params.Keys {
+		bindParam(param, keys[j])
+	}
+
+	return &frame{vars: vars, parent: c.env}
+}
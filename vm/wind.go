@@ -0,0 +1,124 @@
+package vm
+
+import (
+	"whitehouse.id.au/microlisp/compile"
+	"whitehouse.id.au/microlisp/value"
+)
+
+// wind is a call to dynamic-wind in progress. The calls in progress
+// form a list, from the innermost outwards, which is shared by the
+// continuations captured within them.
+type wind struct {
+	before, after value.Value
+	parent        *wind
+}
+
+// depth returns the number of calls in the list.
+func (w *wind) depth() int {
+	n := 0
+	for ; w != nil; w = w.parent {
+		n++
+	}
+	return n
+}
+
+// rewind invokes the after thunks of the dynamic-wind calls in
+// progress that are not in the list to, from the innermost outwards,
+// and then the before thunks of the calls in to that are not in
+// progress, from the outermost inwards.
+//
+// Each thunk is invoked in the dynamic context of its call to
+// dynamic-wind, so a call is left before its after thunk is invoked,
+// and entered after its before thunk returns.
+func (s *state) rewind(to *wind) {
+	from := s.winds
+	leave := func() {
+		s.winds = from.parent
+		invoke(from.after, []value.Value{})
+		from = from.parent
+	}
+
+	var enter []*wind
+	i, j := from.depth(), to.depth()
+	for ; i > j; i-- {
+		leave()
+	}
+	for ; j > i; j-- {
+		enter = append(enter, to)
+		to = to.parent
+	}
+	for from != to {
+		leave()
+		enter = append(enter, to)
+		to = to.parent
+	}
+
+	for i := len(enter) - 1; i >= 0; i-- {
+		invoke(enter[i].before, []value.Value{})
+		s.winds = enter[i]
+	}
+}
+
+var (
+	// windFn and unwindFn enter and leave a call to dynamic-wind.
+	// They are only called by the code of dynamicWind.
+	windFn   = value.FuncX(2, notCallable)
+	unwindFn = value.FuncX(0, notCallable)
+
+	// dynamicWind is the code of the dynamic-wind primitive when
+	// called by the machine:
+	//
+	//	(lambda (before thunk after)
+	//	  (before)
+	//	  (wind before after)
+	//	  (let ((v (thunk)))
+	//	    (unwind)
+	//	    (after)
+	//	    v))
+	dynamicWind = func() *compile.Code {
+		sym := value.Intern
+		list := func(vs ...value.Value) value.Value {
+			return value.List(vs)
+		}
+		before, thunk, after, v := sym("before"), sym("thunk"), sym("after"), sym("v")
+		lambda := list(sym("lambda"), list(before, thunk, after),
+			list(before),
+			list(windFn, before, after),
+			list(sym("let"), list(list(v, list(thunk))),
+				list(unwindFn),
+				list(after),
+				v))
+
+		code := compile.Compile(lambda, value.NewEnv(nil)).Consts[0].(*compile.Code)
+		code.Name = "dynamic-wind"
+		return code
+	}()
+)
+
+// notCallable is the implementation of functions that are only called
+// by the machine.
+func notCallable([]value.Value) value.Value {
+	panic("not possible")
+}
+
+// ignoreErrors is the code of the ignore-errors primitive when called
+// by the machine, which traps errors with a handler, so that the thunk
+// is called by the run:
+//
+//	(lambda (thunk)
+//	  (handler-case (thunk)
+//	    (error (c) c)))
+var ignoreErrors = func() *compile.Code {
+	sym := value.Intern
+	list := func(vs ...value.Value) value.Value {
+		return value.List(vs)
+	}
+	thunk, c := sym("thunk"), sym("c")
+	lambda := list(sym("lambda"), list(thunk),
+		list(sym("handler-case"), list(thunk),
+			list(sym("error"), list(c), c)))
+
+	code := compile.Compile(lambda, value.SystemEnvironment).Consts[0].(*compile.Code)
+	code.Name = "ignore-errors"
+	return code
+}()